@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+	"github.com/gorilla/mux"
+)
+
+// workflowIDPattern matches the UUIDs Conductor assigns as workflow ids.
+var workflowIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// defaultCancelReason is recorded on workflows cancelled without ?reason=.
+const defaultCancelReason = "Cancelled via the onboarding API"
+
+// isTerminalWorkflowStatus reports whether a workflow in status can no longer
+// change state.
+func isTerminalWorkflowStatus(status model.WorkflowStatus) bool {
+	for _, s := range model.WorkflowTerminalStates {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// cancelOnboardHandler terminates a running onboarding workflow. Unknown and
+// already finished workflows are a 404: the running workflow the request
+// names doesn't exist, whereas retryOnboardHandler answers 409 for a workflow
+// that exists but can't be retried in its state. With
+// ?trigger_failure_workflow=true the workflow's failure workflow is started
+// as well.
+func cancelOnboardHandler(w http.ResponseWriter, r *http.Request) {
+	workflowID := mux.Vars(r)["workflow_id"]
+	if !workflowIDPattern.MatchString(workflowID) {
+		http.Error(w, "Invalid workflow id", http.StatusBadRequest)
+		return
+	}
+
+	state, err := wfExecutor.GetWorkflowStatusWithContext(r.Context(), workflowID, false, false)
+	if err != nil {
+		log.Printf("API: failed to get workflow %s: %v", workflowID, err)
+		http.Error(w, "Failed to get workflow", http.StatusInternalServerError)
+		return
+	}
+	if state == nil || isTerminalWorkflowStatus(model.WorkflowStatus(state.Status)) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	reason := r.URL.Query().Get("reason")
+	if reason == "" {
+		reason = defaultCancelReason
+	}
+	if r.URL.Query().Get("trigger_failure_workflow") == "true" {
+		err = wfExecutor.TerminateWithFailureWithContext(r.Context(), workflowID, reason, true)
+	} else {
+		err = wfExecutor.TerminateWithContext(r.Context(), workflowID, reason)
+	}
+	if err != nil {
+		log.Printf("API: failed to terminate workflow %s: %v", workflowID, err)
+		if apiErr, ok := asConductorAPIError(err); ok {
+			if apiErr.Code == http.StatusNotFound {
+				http.Error(w, "Not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to cancel workflow: "+apiErr.Message, http.StatusBadGateway)
+			return
+		}
+		http.Error(w, "Failed to cancel workflow", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("API: terminated workflow %s (request %s): %s", workflowID, state.CorrelationId, reason)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":      "Workflow cancelled",
+		"workflow_id": workflowID,
+		"request_id":  state.CorrelationId,
+	})
+}
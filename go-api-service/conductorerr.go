@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/conductor-sdk/conductor-go/sdk/client"
+)
+
+// ConductorAPIError is an error response of the Conductor server, decoded from
+// its JSON body such as
+//
+//	{"status": 404, "message": "No such workflow found by name: x", "retryable": false}
+type ConductorAPIError struct {
+	Code      int
+	Message   string
+	Retryable bool
+}
+
+func (e *ConductorAPIError) Error() string {
+	return fmt.Sprintf("conductor responded with %d: %s", e.Code, e.Message)
+}
+
+// conductorErrorBody is the JSON error body of the Conductor server.
+type conductorErrorBody struct {
+	Status    int    `json:"status"`
+	Message   string `json:"message"`
+	Retryable *bool  `json:"retryable"`
+}
+
+// asConductorAPIError extracts the Conductor error response from an SDK
+// client error. Bodies that aren't JSON keep their raw text as the message;
+// without a retryable flag, server errors and throttling are retryable. It
+// returns false for errors that carry no response, such as network errors.
+func asConductorAPIError(err error) (*ConductorAPIError, bool) {
+	var swaggerErr client.GenericSwaggerError
+	if !errors.As(err, &swaggerErr) || swaggerErr.StatusCode() == 0 {
+		return nil, false
+	}
+	apiErr := &ConductorAPIError{Code: swaggerErr.StatusCode(), Message: string(swaggerErr.Body())}
+	var body conductorErrorBody
+	if json.Unmarshal(swaggerErr.Body(), &body) == nil {
+		if body.Status != 0 {
+			apiErr.Code = body.Status
+		}
+		if body.Message != "" {
+			apiErr.Message = body.Message
+		}
+		if body.Retryable != nil {
+			apiErr.Retryable = *body.Retryable
+			return apiErr, true
+		}
+	}
+	apiErr.Retryable = apiErr.Code >= 500 || apiErr.Code == http.StatusTooManyRequests
+	return apiErr, true
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/conductor-sdk/conductor-go/sdk/client"
+)
+
+func TestAsConductorAPIError(t *testing.T) {
+	swaggerErr := func(status int, body string) error {
+		return client.NewGenericSwaggerError([]byte(body), "", nil, status)
+	}
+	tests := []struct {
+		name string
+		err  error
+		want *ConductorAPIError
+	}{
+		{
+			name: "JSON body",
+			err:  swaggerErr(404, `{"status": 404, "message": "No such workflow found by name: x", "retryable": false}`),
+			want: &ConductorAPIError{Code: 404, Message: "No such workflow found by name: x"},
+		},
+		{
+			name: "body status wins",
+			err:  swaggerErr(500, `{"status": 409, "message": "conflict"}`),
+			want: &ConductorAPIError{Code: 409, Message: "conflict"},
+		},
+		{
+			name: "retryable flag wins",
+			err:  swaggerErr(500, `{"status": 500, "message": "boom", "retryable": false}`),
+			want: &ConductorAPIError{Code: 500, Message: "boom"},
+		},
+		{
+			name: "server error retryable without a flag",
+			err:  swaggerErr(503, `{"message": "unavailable"}`),
+			want: &ConductorAPIError{Code: 503, Message: "unavailable", Retryable: true},
+		},
+		{
+			name: "throttling retryable without a flag",
+			err:  swaggerErr(429, `{"message": "slow down"}`),
+			want: &ConductorAPIError{Code: 429, Message: "slow down", Retryable: true},
+		},
+		{
+			name: "non-JSON body kept as the message",
+			err:  swaggerErr(502, "Bad Gateway"),
+			want: &ConductorAPIError{Code: 502, Message: "Bad Gateway", Retryable: true},
+		},
+		{
+			name: "wrapped",
+			err:  fmt.Errorf("start workflow: %w", swaggerErr(400, `{"status": 400, "message": "invalid input"}`)),
+			want: &ConductorAPIError{Code: 400, Message: "invalid input"},
+		},
+		{name: "no response", err: swaggerErr(0, "")},
+		{name: "network error", err: errors.New("dial tcp: connection refused")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := asConductorAPIError(tt.err)
+			if ok != (tt.want != nil) {
+				t.Fatalf("ok = %v, want %v", ok, tt.want != nil)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("asConductorAPIError() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConductorAPIErrorError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *ConductorAPIError
+		want string
+	}{
+		{name: "not found", err: &ConductorAPIError{Code: 404, Message: "No such workflow"}, want: "conductor responded with 404: No such workflow"},
+		{name: "empty message", err: &ConductorAPIError{Code: 500}, want: "conductor responded with 500: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,13 +3,13 @@ module servicedemo
 go 1.25.3
 
 require (
+	github.com/antihax/optional v1.0.0
 	github.com/conductor-sdk/conductor-go v1.6.1
 	github.com/gorilla/mux v1.8.1
 	github.com/lib/pq v1.10.9
 )
 
 require (
-	github.com/antihax/optional v1.0.0 // indirect
 	github.com/beorn7/perks v1.0.1 // indirect
 	github.com/cespare/xxhash/v2 v2.1.2 // indirect
 	github.com/golang/protobuf v1.5.2 // indirect
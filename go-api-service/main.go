@@ -1,26 +1,60 @@
 package main
 
 import (
+	"crypto/rand"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"regexp"
 	"strconv"
+	"time"
 
 	"github.com/conductor-sdk/conductor-go/sdk/client"
 	"github.com/conductor-sdk/conductor-go/sdk/model"
 	"github.com/conductor-sdk/conductor-go/sdk/settings"
 	"github.com/conductor-sdk/conductor-go/sdk/workflow/executor"
 	"github.com/gorilla/mux"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // OnboardRequest Define the request structure for the API
 type OnboardRequest struct {
-	EntpName string `json:"entp_name"`
-	UserName string `json:"user_name"`
+	EntpName  string `json:"entp_name"`
+	UserName  string `json:"user_name"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// requestIDKey is the workflow input key carrying the request id to the workers.
+const requestIDKey = "request_id"
+
+// newRequestID returns a random (version 4) UUID.
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		log.Printf("API: failed to generate request id: %v", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// schemaNamePattern restricts DB_SCHEMA to plain lowercase identifiers of at
+// most 63 bytes. The name is passed unquoted in the search_path of the
+// connection string, where Postgres folds it to lowercase, so only a lowercase
+// name finds the schema created under its quoted name.
+var schemaNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// dbSchema returns the schema named by DB_SCHEMA, public by default.
+func dbSchema() (string, error) {
+	schema := getEnv("DB_SCHEMA", "public")
+	if !schemaNamePattern.MatchString(schema) {
+		return "", fmt.Errorf("invalid DB_SCHEMA %q: want a lowercase name of letters, digits and underscores", schema)
+	}
+	return schema, nil
 }
 
 // getEnv returns the value of the environment variable if set, otherwise the provided default.
@@ -31,22 +65,67 @@ func getEnv(key, def string) string {
 	return def
 }
 
+// maxBodyBytes caps the size of request bodies (MAX_REQUEST_BODY_BYTES), which
+// may end up as workflow input in Conductor.
+var maxBodyBytes int64 = 1 << 20
+
+// decodeJSONBody decodes the JSON request body into dst, reading at most
+// maxBodyBytes. On failure it writes the error response, 413 when the body is
+// too large and 400 otherwise, and returns false.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Request body exceeds %d bytes", maxBodyBytes), http.StatusRequestEntityTooLarge)
+			return false
+		}
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
 // Conductor SDK workflow executor
 var wfExecutor *executor.WorkflowExecutor
 
+// wfClient calls the Conductor workflow API directly, for the responses the
+// executor doesn't pass on, such as the status code of a failed lookup.
+var wfClient *client.WorkflowResourceApiService
+
 // Shared DB connection for user service
 var db *sql.DB
 
+// workflowVersion is the onboarding workflow version to start (WORKFLOW_VERSION).
+// Zero lets Conductor pick the latest version.
+var workflowVersion int32
+
+// parseWorkflowVersion parses WORKFLOW_VERSION, which must be a non-negative integer.
+func parseWorkflowVersion(v string) (int32, error) {
+	n, err := strconv.ParseInt(v, 10, 32)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid WORKFLOW_VERSION %q: must be a non-negative integer", v)
+	}
+	return int32(n), nil
+}
+
 // TODO: create generic struct for conductor config
 // TODO: create a load/new method that return this config
 
+// Conductor client settings, kept for the startup config log
+var (
+	conductorAPIURL string
+	conductorAuth   *settings.AuthenticationSettings
+)
+
 func init() {
 	// Configure Conductor API URL via environment (same as worker)
-	apiURL := getEnv("CONDUCTOR_API_URL", "http://localhost:8080/api")
-	auth := &settings.AuthenticationSettings{}
-	httpSettings := &settings.HttpSettings{BaseUrl: apiURL}
-	apiClient := client.NewAPIClient(auth, httpSettings)
+	conductorAPIURL = getEnv("CONDUCTOR_API_URL", "http://localhost:8080/api")
+	conductorAuth = &settings.AuthenticationSettings{}
+	httpSettings := &settings.HttpSettings{BaseUrl: conductorAPIURL}
+	apiClient := client.NewAPIClient(conductorAuth, httpSettings)
 	wfExecutor = executor.NewWorkflowExecutor(apiClient)
+	wfClient = &client.WorkflowResourceApiService{APIClient: apiClient}
 }
 
 // initDB initializes the Postgres connection and ensures tables exist
@@ -56,9 +135,13 @@ func initDB() error {
 	user := getEnv("DB_USER", "user")
 	password := getEnv("DB_PASSWORD", "password")
 	dbname := getEnv("DB_NAME", "conductor")
+	// Tables live in DB_SCHEMA so several tenants can share one database
+	schema, err := dbSchema()
+	if err != nil {
+		return err
+	}
 
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
-	var err error
+	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable search_path=%s", host, port, user, password, dbname, schema)
 	db, err = sql.Open("postgres", connStr)
 	if err != nil {
 		return fmt.Errorf("error opening database: %w", err)
@@ -66,18 +149,25 @@ func initDB() error {
 	if err = db.Ping(); err != nil {
 		return fmt.Errorf("error connecting to database: %w", err)
 	}
+	if _, err = db.Exec("CREATE SCHEMA IF NOT EXISTS " + pq.QuoteIdentifier(schema)); err != nil {
+		return fmt.Errorf("error creating schema %s: %w", schema, err)
+	}
 	// Ensure tables exist (idempotent)
 	_, err = db.Exec(`
         CREATE TABLE IF NOT EXISTS enterprise (
             id SERIAL PRIMARY KEY,
             name VARCHAR(255) UNIQUE NOT NULL,
-            details TEXT
+            details TEXT,
+            active BOOLEAN NOT NULL DEFAULT true
         );
+        ALTER TABLE enterprise ADD COLUMN IF NOT EXISTS active BOOLEAN NOT NULL DEFAULT true;
         CREATE TABLE IF NOT EXISTS "user" (
             id SERIAL PRIMARY KEY,
             enterprise_id INT REFERENCES enterprise(id),
-            username VARCHAR(255) UNIQUE NOT NULL
+            username VARCHAR(255) UNIQUE NOT NULL,
+            details JSONB
         );
+        ALTER TABLE "user" ADD COLUMN IF NOT EXISTS details JSONB;
         CREATE TABLE IF NOT EXISTS worker_state (
             task_id VARCHAR(128) PRIMARY KEY,
             workflow_id VARCHAR(128),
@@ -93,15 +183,14 @@ func initDB() error {
 	if err != nil {
 		return fmt.Errorf("error creating tables: %w", err)
 	}
-	log.Println("API: Database connection successful and tables checked.")
+	log.Printf("API: Database connection successful and tables checked in schema %s.", schema)
 	return nil
 }
 
 // onboardHandler triggers the Conductor workflow
 func onboardHandler(w http.ResponseWriter, r *http.Request) {
 	var req OnboardRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeJSONBody(w, r, &req) {
 		return
 	}
 
@@ -110,31 +199,60 @@ func onboardHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The request id ties together the API, workflow and worker logs
+	if req.RequestID == "" {
+		req.RequestID = r.Header.Get("X-Request-ID")
+	}
+	if req.RequestID == "" {
+		req.RequestID = newRequestID()
+	}
+
+	// The trace context travels with the workflow input so worker logs can be
+	// stitched to the caller's trace
+	traceparent := traceparentFor(r)
+	logPrefix := fmt.Sprintf("[request_id=%s trace_id=%s]", req.RequestID, traceIDOf(traceparent))
+
 	// 1. Define the input data for the Conductor workflow
 	workflowInput := map[string]interface{}{
-		"entp_name": req.EntpName,
-		"user_name": req.UserName,
+		"entp_name":    req.EntpName,
+		"user_name":    req.UserName,
+		requestIDKey:   req.RequestID,
+		traceparentKey: traceparent,
 	}
 
 	// 2. Start the workflow via Conductor SDK
 	startReq := &model.StartWorkflowRequest{
-		Name:    "onboard_employee_workflow",
-		Version: int32(1),
-		Input:   workflowInput,
+		Name:          "onboard_employee_workflow",
+		Version:       workflowVersion,
+		Input:         workflowInput,
+		CorrelationId: req.RequestID,
 	}
 	workflowID, err := wfExecutor.StartWorkflow(startReq)
 	if err != nil {
-		log.Printf("Error starting workflow: %v", err)
+		log.Printf("%s Error starting workflow: %v", logPrefix, err)
+		// Report Conductor's own message; retryable failures are a 503 so
+		// clients know to try again
+		if apiErr, ok := asConductorAPIError(err); ok {
+			status := http.StatusBadGateway
+			if apiErr.Retryable {
+				status = http.StatusServiceUnavailable
+			}
+			http.Error(w, "Failed to start workflow: "+apiErr.Message, status)
+			return
+		}
 		http.Error(w, "Failed to start workflow: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("Workflow 'onboard_employee_workflow' started with ID: %s", workflowID)
+	log.Printf("%s Workflow 'onboard_employee_workflow' started with ID: %s", logPrefix, workflowID)
+	recentWorkflows.Add(StartedWorkflow{WorkflowID: workflowID, CorrelationID: req.RequestID, CreatedAt: time.Now()})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
 		"status":      "Workflow started successfully",
 		"workflow_id": workflowID,
+		"request_id":  req.RequestID,
+		"traceparent": traceparent,
 	})
 }
 
@@ -154,8 +272,7 @@ type User struct {
 // createUserHandler inserts a new user into the DB
 func createUserHandler(w http.ResponseWriter, r *http.Request) {
 	var req UserCreateRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeJSONBody(w, r, &req) {
 		return
 	}
 	if req.EnterpriseID <= 0 || req.UserName == "" {
@@ -166,6 +283,11 @@ func createUserHandler(w http.ResponseWriter, r *http.Request) {
 	var userID int
 	err := db.QueryRow(`INSERT INTO "user" (enterprise_id, username) VALUES ($1, $2) RETURNING id`, req.EnterpriseID, req.UserName).Scan(&userID)
 	if err != nil {
+		// Unique constraint violation on the username
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			http.Error(w, fmt.Sprintf("User '%s' already exists", req.UserName), http.StatusConflict)
+			return
+		}
 		log.Printf("API: failed to create user: %v", err)
 		http.Error(w, "Failed to create user", http.StatusInternalServerError)
 		return
@@ -221,21 +343,92 @@ func getUserHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(u)
 }
 
+// EnterpriseUpdateRequest is the payload to update an enterprise
+type EnterpriseUpdateRequest struct {
+	Active *bool `json:"active"`
+}
+
+// Enterprise represents an enterprise record
+type Enterprise struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+}
+
+// updateEnterpriseHandler toggles whether an enterprise is active. Onboarding
+// into an inactive enterprise fails.
+func updateEnterpriseHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		http.Error(w, "Invalid enterprise id", http.StatusBadRequest)
+		return
+	}
+	var req EnterpriseUpdateRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.Active == nil {
+		http.Error(w, "active is required", http.StatusBadRequest)
+		return
+	}
+
+	var e Enterprise
+	err = db.QueryRow(`UPDATE enterprise SET active = $1 WHERE id = $2 RETURNING id, name, active`, *req.Active, id).Scan(&e.ID, &e.Name, &e.Active)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("API: failed to update enterprise: %v", err)
+		http.Error(w, "Failed to update enterprise", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("API: enterprise %d (%s) active=%t", e.ID, e.Name, e.Active)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(e)
+}
+
 func main() {
 	// Initialize DB for user service
 	if err := initDB(); err != nil {
 		log.Fatalf("API: DB initialization failed: %v", err)
 	}
 
+	var err error
+	if workflowVersion, err = parseWorkflowVersion(getEnv("WORKFLOW_VERSION", "1")); err != nil {
+		log.Fatalf("API: %v", err)
+	}
+	if workflowVersion == 0 {
+		log.Println("API: starting the latest version of onboard_employee_workflow")
+	} else {
+		log.Printf("API: starting version %d of onboard_employee_workflow", workflowVersion)
+	}
+
+	if maxBodyBytes, err = strconv.ParseInt(getEnv("MAX_REQUEST_BODY_BYTES", "1048576"), 10, 64); err != nil || maxBodyBytes <= 0 {
+		log.Fatalf("API: invalid MAX_REQUEST_BODY_BYTES %q", getEnv("MAX_REQUEST_BODY_BYTES", "1048576"))
+	}
+	recentSize, err := strconv.Atoi(getEnv("RECENT_WORKFLOWS_SIZE", "100"))
+	if err != nil || recentSize < 0 {
+		log.Fatalf("API: invalid RECENT_WORKFLOWS_SIZE %q", getEnv("RECENT_WORKFLOWS_SIZE", "100"))
+	}
+	recentWorkflows = newStartedWorkflows(recentSize)
+
 	router := mux.NewRouter()
 	// Workflow trigger endpoint
 	router.HandleFunc("/onboard", onboardHandler).Methods("POST")
+	router.HandleFunc("/onboard/recent", recentWorkflowsHandler).Methods("GET")
+	router.HandleFunc("/onboard/{workflow_id}", onboardStatusHandler).Methods("GET")
+	router.HandleFunc("/onboard/{workflow_id}", cancelOnboardHandler).Methods("DELETE")
+	router.HandleFunc("/onboard/{workflow_id}/retry", retryOnboardHandler).Methods("POST")
 
 	// User service endpoints
 	router.HandleFunc("/users", createUserHandler).Methods("POST")
 	router.HandleFunc("/users", listUsersHandler).Methods("GET")
 	router.HandleFunc("/users/{id}", getUserHandler).Methods("GET")
 
+	// Enterprise endpoints
+	router.HandleFunc("/enterprises/{id}", updateEnterpriseHandler).Methods("PATCH")
+
+	logStartupConfig(conductorAPIURL, conductorAuth)
 	log.Println("API Service running on :8081")
 	if err := http.ListenAndServe(":8081", router); err != nil {
 		log.Fatal(err)
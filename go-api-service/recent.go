@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// StartedWorkflow is a workflow started by this API instance.
+type StartedWorkflow struct {
+	WorkflowID    string    `json:"workflow_id"`
+	CorrelationID string    `json:"correlation_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// startedWorkflows is a bounded ring buffer of the workflows started most
+// recently. It lives in the process memory only: it is empty after a
+// restart and each API replica only knows its own workflows.
+type startedWorkflows struct {
+	mu      sync.Mutex
+	entries []StartedWorkflow
+	next    int
+	full    bool
+}
+
+func newStartedWorkflows(size int) *startedWorkflows {
+	return &startedWorkflows{entries: make([]StartedWorkflow, size)}
+}
+
+// Add records a started workflow, evicting the oldest one when full.
+func (s *startedWorkflows) Add(wf StartedWorkflow) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) == 0 {
+		return
+	}
+	s.entries[s.next] = wf
+	s.next = (s.next + 1) % len(s.entries)
+	if s.next == 0 {
+		s.full = true
+	}
+}
+
+// List returns the recorded workflows, most recent first.
+func (s *startedWorkflows) List() []StartedWorkflow {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.next
+	if s.full {
+		n = len(s.entries)
+	}
+	out := make([]StartedWorkflow, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, s.entries[(s.next-i+len(s.entries))%len(s.entries)])
+	}
+	return out
+}
+
+// recentWorkflows keeps the last RECENT_WORKFLOWS_SIZE workflows started.
+var recentWorkflows *startedWorkflows
+
+// recentWorkflowsHandler lists the workflows recently started by this API
+// instance, most recent first.
+func recentWorkflowsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(recentWorkflows.List())
+}
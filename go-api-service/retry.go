@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+	"github.com/gorilla/mux"
+)
+
+// retryOnboardHandler retries a failed or timed out onboarding workflow from
+// its last failed task, resuming a failed sub-workflow rather than re-running
+// it. Unknown workflows are a 404 and workflows in any other state a 409,
+// unlike cancelOnboardHandler, which treats finished workflows as unknown.
+func retryOnboardHandler(w http.ResponseWriter, r *http.Request) {
+	workflowID := mux.Vars(r)["workflow_id"]
+	if !workflowIDPattern.MatchString(workflowID) {
+		http.Error(w, "Invalid workflow id", http.StatusBadRequest)
+		return
+	}
+
+	state, err := wfExecutor.GetWorkflowStatusWithContext(r.Context(), workflowID, false, false)
+	if err != nil {
+		log.Printf("API: failed to get workflow %s: %v", workflowID, err)
+		http.Error(w, "Failed to get workflow", http.StatusInternalServerError)
+		return
+	}
+	if state == nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	switch model.WorkflowStatus(state.Status) {
+	case model.FailedWorkflow, model.TimedOutWorkflow:
+	default:
+		http.Error(w, "Workflow is "+state.Status+", only failed workflows can be retried", http.StatusConflict)
+		return
+	}
+
+	if err := wfExecutor.RetryWithContext(r.Context(), workflowID, true); err != nil {
+		log.Printf("API: failed to retry workflow %s: %v", workflowID, err)
+		if apiErr, ok := asConductorAPIError(err); ok {
+			status := http.StatusBadGateway
+			switch {
+			case apiErr.Code == http.StatusNotFound:
+				status = http.StatusNotFound
+			case apiErr.Code == http.StatusConflict:
+				status = http.StatusConflict
+			case apiErr.Retryable:
+				status = http.StatusServiceUnavailable
+			}
+			http.Error(w, "Failed to retry workflow: "+apiErr.Message, status)
+			return
+		}
+		http.Error(w, "Failed to retry workflow", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[request_id=%s] API: retrying %s workflow %s", state.CorrelationId, state.Status, workflowID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":      "Workflow retried",
+		"workflow_id": workflowID,
+		"request_id":  state.CorrelationId,
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+
+	sdklog "github.com/conductor-sdk/conductor-go/sdk/log"
+	"github.com/conductor-sdk/conductor-go/sdk/settings"
+)
+
+// startupConfig is the effective configuration logged by logStartupConfig.
+type startupConfig struct {
+	ConductorURL    string `json:"conductor_url"`
+	AuthMode        string `json:"auth_mode"`
+	DBHost          string `json:"db_host"`
+	DBName          string `json:"db_name"`
+	DBSchema        string `json:"db_schema"`
+	WorkflowVersion int32  `json:"workflow_version"`
+	MaxBodyBytes    int64  `json:"max_request_body_bytes"`
+}
+
+// logStartupConfig logs the effective configuration as a single JSON line
+// through the SDK logger. The database password is left out and credentials
+// in the Conductor URL are redacted.
+func logStartupConfig(apiURL string, auth *settings.AuthenticationSettings) {
+	cfg := startupConfig{
+		ConductorURL:    redactURL(apiURL),
+		AuthMode:        authMode(auth),
+		DBHost:          getEnv("DB_HOST", "localhost"),
+		DBName:          getEnv("DB_NAME", "conductor"),
+		DBSchema:        getEnv("DB_SCHEMA", "public"),
+		WorkflowVersion: workflowVersion,
+		MaxBodyBytes:    maxBodyBytes,
+	}
+	line, err := json.Marshal(cfg)
+	if err != nil {
+		sdklog.Error("Failed to encode startup config", "error", err)
+		return
+	}
+	sdklog.Info("Startup config", "config", string(line))
+}
+
+// authMode names how the Conductor client authenticates.
+func authMode(auth *settings.AuthenticationSettings) string {
+	if auth == nil || auth.IsEmpty() {
+		return "none"
+	}
+	return "key"
+}
+
+// redactURL returns rawURL with any password replaced, or a placeholder if it
+// doesn't parse.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "***"
+	}
+	return u.Redacted()
+}
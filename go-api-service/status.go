@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/antihax/optional"
+	"github.com/conductor-sdk/conductor-go/sdk/client"
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+	"github.com/gorilla/mux"
+)
+
+// TaskFailure describes why one task of a workflow failed.
+type TaskFailure struct {
+	ReferenceName string `json:"reference_name"`
+	TaskType      string `json:"task_type"`
+	Reason        string `json:"reason"`
+}
+
+// workflowFailureSummary returns one TaskFailure per failed task of wf. It is
+// empty unless the workflow itself failed, timed out or was terminated.
+func workflowFailureSummary(wf *model.Workflow) []TaskFailure {
+	return failureSummary(wf.Status, wf.GetFailedTasks())
+}
+
+func failureSummary(status model.WorkflowStatus, failed []model.Task) []TaskFailure {
+	out := []TaskFailure{}
+	switch status {
+	case model.FailedWorkflow, model.TimedOutWorkflow, model.TerminatedWorkflow:
+	default:
+		return out
+	}
+	for _, t := range failed {
+		out = append(out, TaskFailure{
+			ReferenceName: t.ReferenceTaskName,
+			TaskType:      t.TaskType,
+			Reason:        t.ReasonForIncompletion,
+		})
+	}
+	return out
+}
+
+// TaskTimelineEntry is one task of a workflow timeline. Times are Unix epoch
+// milliseconds and are omitted while unknown, e.g. for tasks not started yet.
+type TaskTimelineEntry struct {
+	ReferenceName string                 `json:"reference_name"`
+	Status        model.TaskResultStatus `json:"status"`
+	StartTime     int64                  `json:"start_time,omitempty"`
+	EndTime       int64                  `json:"end_time,omitempty"`
+	// DurationMs is only set for tasks that have both started and ended.
+	DurationMs int64 `json:"duration_ms,omitempty"`
+	// ClockSkew is set when the task ended before it started, which only
+	// happens when the clocks of the Conductor nodes disagree; DurationMs is
+	// then clamped to zero.
+	ClockSkew bool `json:"clock_skew,omitempty"`
+}
+
+// durationMs returns the milliseconds between the epoch millisecond times
+// start and end, or zero if either is unknown. An end before start is taken
+// for clock skew between nodes: the duration is clamped to zero and skewed is
+// set.
+func durationMs(start, end int64) (ms int64, skewed bool) {
+	if start <= 0 || end <= 0 {
+		return 0, false
+	}
+	if end < start {
+		return 0, true
+	}
+	return end - start, false
+}
+
+// workflowTimeline returns the tasks of wf ordered by start time.
+func workflowTimeline(wf *model.Workflow) []TaskTimelineEntry {
+	return timeline(wf.Tasks)
+}
+
+// timeline orders tasks by start time. Tasks that have not started keep their
+// workflow order after the started ones.
+func timeline(tasks []model.Task) []TaskTimelineEntry {
+	out := make([]TaskTimelineEntry, 0, len(tasks))
+	for _, t := range tasks {
+		e := TaskTimelineEntry{
+			ReferenceName: t.ReferenceTaskName,
+			Status:        t.Status,
+			StartTime:     t.StartTime,
+			EndTime:       t.EndTime,
+		}
+		e.DurationMs, e.ClockSkew = durationMs(t.StartTime, t.EndTime)
+		out = append(out, e)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].StartTime == 0 || out[j].StartTime == 0 {
+			return out[j].StartTime == 0 && out[i].StartTime != 0
+		}
+		return out[i].StartTime < out[j].StartTime
+	})
+	return out
+}
+
+// OnboardStatus is the response of the onboarding status endpoint
+type OnboardStatus struct {
+	WorkflowID string                 `json:"workflow_id"`
+	RequestID  string                 `json:"request_id,omitempty"`
+	Status     model.WorkflowStatus   `json:"status"`
+	Reason     string                 `json:"reason,omitempty"`
+	Output     map[string]interface{} `json:"output,omitempty"`
+	Variables  map[string]interface{} `json:"variables,omitempty"`
+	Failures   []TaskFailure          `json:"failures"`
+	Timeline   []TaskTimelineEntry    `json:"timeline"`
+}
+
+// onboardStatusHandler reports the state of an onboarding workflow, the
+// timeline of its tasks and, when it failed, which tasks failed and why. With
+// ?include_variables=true it also returns the workflow variables, which the
+// execution fetched for the timeline already carries. Unknown workflows are a
+// 404; other Conductor errors a 503 when retryable, a 502 otherwise.
+func onboardStatusHandler(w http.ResponseWriter, r *http.Request) {
+	workflowID := mux.Vars(r)["workflow_id"]
+	if !workflowIDPattern.MatchString(workflowID) {
+		http.Error(w, "Invalid workflow id", http.StatusBadRequest)
+		return
+	}
+
+	wf, _, err := wfClient.GetExecutionStatus(r.Context(), workflowID, &client.WorkflowResourceApiGetExecutionStatusOpts{
+		IncludeTasks: optional.NewBool(true),
+	})
+	if err != nil {
+		log.Printf("API: failed to get workflow %s: %v", workflowID, err)
+		if apiErr, ok := asConductorAPIError(err); ok {
+			status := http.StatusBadGateway
+			switch {
+			case apiErr.Code == http.StatusNotFound:
+				http.Error(w, "Not found", http.StatusNotFound)
+				return
+			case apiErr.Retryable:
+				status = http.StatusServiceUnavailable
+			}
+			http.Error(w, "Failed to get workflow: "+apiErr.Message, status)
+			return
+		}
+		http.Error(w, "Failed to get workflow", http.StatusInternalServerError)
+		return
+	}
+
+	status := OnboardStatus{
+		WorkflowID: wf.WorkflowId,
+		RequestID:  wf.CorrelationId,
+		Status:     wf.Status,
+		Reason:     wf.ReasonForIncompletion,
+		Output:     wf.Output,
+		Failures:   workflowFailureSummary(&wf),
+		Timeline:   workflowTimeline(&wf),
+	}
+	if r.URL.Query().Get("include_variables") == "true" {
+		status.Variables = wf.Variables
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+func TestDurationMs(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end int64
+		wantMs     int64
+		wantSkewed bool
+	}{
+		{name: "ended", start: 1000, end: 1250, wantMs: 250},
+		{name: "ended at once", start: 1000, end: 1000},
+		{name: "not started", end: 1250},
+		{name: "not ended", start: 1000},
+		{name: "ended before it started", start: 1250, end: 1000, wantSkewed: true},
+		{name: "skewed by a millisecond", start: 1001, end: 1000, wantSkewed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms, skewed := durationMs(tt.start, tt.end)
+			if ms != tt.wantMs || skewed != tt.wantSkewed {
+				t.Errorf("durationMs(%d, %d) = %d, %v, want %d, %v", tt.start, tt.end, ms, skewed, tt.wantMs, tt.wantSkewed)
+			}
+		})
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	tests := []struct {
+		name  string
+		tasks []model.Task
+		want  []TaskTimelineEntry
+	}{
+		{name: "no tasks", want: []TaskTimelineEntry{}},
+		{
+			name: "ordered by start time",
+			tasks: []model.Task{
+				{ReferenceTaskName: "b", Status: model.CompletedTask, StartTime: 2000, EndTime: 2500},
+				{ReferenceTaskName: "a", Status: model.CompletedTask, StartTime: 1000, EndTime: 1100},
+			},
+			want: []TaskTimelineEntry{
+				{ReferenceName: "a", Status: model.CompletedTask, StartTime: 1000, EndTime: 1100, DurationMs: 100},
+				{ReferenceName: "b", Status: model.CompletedTask, StartTime: 2000, EndTime: 2500, DurationMs: 500},
+			},
+		},
+		{
+			name: "skewed task clamped and flagged",
+			tasks: []model.Task{
+				{ReferenceTaskName: "a", Status: model.CompletedTask, StartTime: 1000, EndTime: 1100},
+				{ReferenceTaskName: "b", Status: model.CompletedTask, StartTime: 1200, EndTime: 1150},
+			},
+			want: []TaskTimelineEntry{
+				{ReferenceName: "a", Status: model.CompletedTask, StartTime: 1000, EndTime: 1100, DurationMs: 100},
+				{ReferenceName: "b", Status: model.CompletedTask, StartTime: 1200, EndTime: 1150, ClockSkew: true},
+			},
+		},
+		{
+			name: "unstarted tasks last in workflow order",
+			tasks: []model.Task{
+				{ReferenceTaskName: "c", Status: model.TaskResultStatus("SCHEDULED")},
+				{ReferenceTaskName: "b", Status: model.InProgressTask, StartTime: 2000},
+				{ReferenceTaskName: "d", Status: model.TaskResultStatus("SCHEDULED")},
+				{ReferenceTaskName: "a", Status: model.CompletedTask, StartTime: 1000, EndTime: 1500},
+			},
+			want: []TaskTimelineEntry{
+				{ReferenceName: "a", Status: model.CompletedTask, StartTime: 1000, EndTime: 1500, DurationMs: 500},
+				{ReferenceName: "b", Status: model.InProgressTask, StartTime: 2000},
+				{ReferenceName: "c", Status: model.TaskResultStatus("SCHEDULED")},
+				{ReferenceName: "d", Status: model.TaskResultStatus("SCHEDULED")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := timeline(tt.tasks); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("timeline() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// traceparentKey is the header, and workflow input key, carrying the W3C
+// trace context (https://www.w3.org/TR/trace-context/).
+const traceparentKey = "traceparent"
+
+var traceparentPattern = regexp.MustCompile(`^00-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+
+// zeroTraceID is the invalid all-zero trace id.
+const zeroTraceID = "00000000000000000000000000000000"
+
+// traceparentFor returns the trace context of the workflow started by r: a new
+// span of the trace in r's traceparent header, or of a new sampled trace when
+// the header is missing or invalid.
+func traceparentFor(r *http.Request) string {
+	traceID, flags := randomHex(16), "01"
+	if m := traceparentPattern.FindStringSubmatch(r.Header.Get(traceparentKey)); m != nil && m[1] != zeroTraceID {
+		traceID, flags = m[1], m[3]
+	}
+	return fmt.Sprintf("00-%s-%s-%s", traceID, randomHex(8), flags)
+}
+
+// traceIDOf returns the trace id of a traceparent produced by traceparentFor.
+func traceIDOf(traceparent string) string {
+	if m := traceparentPattern.FindStringSubmatch(traceparent); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%0*x", n*2, 1)
+	}
+	return fmt.Sprintf("%x", b)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/conductor-sdk/conductor-go/sdk/client"
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// defaultAdminAddr is where the admin server listens without ADMIN_ADDR. It is
+// only reachable from the host unless ADMIN_TOKEN protects it.
+const defaultAdminAddr = "127.0.0.1:8082"
+
+// isLoopbackAddr reports whether the listen address addr only accepts
+// connections from the local host.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// requireAdminToken wraps an admin handler so that it only serves requests
+// carrying "Authorization: Bearer <token>". An empty token disables the check.
+func requireAdminToken(token string, h http.HandlerFunc) http.HandlerFunc {
+	if token == "" {
+		return h
+	}
+	want := []byte("Bearer " + token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="worker-admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// newAdminMux builds the worker admin HTTP API. /metrics is only served when
+// METRICS_ENABLED=true. Every endpoint requires the bearer token adminToken,
+// when not empty, except /ready, for probes, and /callback/{token}, which is
+// authenticated by its callback token.
+func newAdminMux(sup *supervisor, taskClient *client.TaskResourceApiService, workflowClient *client.WorkflowResourceApiService, adminToken string) *http.ServeMux {
+	mux := http.NewServeMux()
+	handle := func(pattern string, h http.HandlerFunc) {
+		mux.HandleFunc(pattern, requireAdminToken(adminToken, h))
+	}
+	mux.HandleFunc("POST /callback/{token}", callbackHandler(taskClient))
+	mux.HandleFunc("GET /ready", readyHandler(sup))
+	handle("POST /batch-size/scale", scaleBatchSizesHandler(sup))
+	handle("POST /workers/{task_name}/boost", boostBatchSizeHandler(sup))
+	handle("POST /tasks/{task_id}/cancel", func(w http.ResponseWriter, r *http.Request) {
+		taskID := r.PathValue("task_id")
+		if !cancelTask(taskID) {
+			http.Error(w, "Task is not running on this worker", http.StatusNotFound)
+			return
+		}
+		log.Printf("Admin: cancelled task %s", taskID)
+		w.WriteHeader(http.StatusAccepted)
+	})
+	handle("POST /workers/{task_name}/pause", func(w http.ResponseWriter, r *http.Request) {
+		taskName := r.PathValue("task_name")
+		if !sup.isRegistered(taskName) {
+			http.Error(w, "Unknown task", http.StatusNotFound)
+			return
+		}
+		cancelled := 0
+		if r.URL.Query().Get("cancel") == "true" {
+			cancelled = sup.PauseWithCancel(taskName)
+		} else {
+			sup.Pause(taskName)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"cancelled": cancelled})
+	})
+	handle("POST /workers/{task_name}/resume", func(w http.ResponseWriter, r *http.Request) {
+		taskName := r.PathValue("task_name")
+		if !sup.isRegistered(taskName) {
+			http.Error(w, "Unknown task", http.StatusNotFound)
+			return
+		}
+		sup.Resume(taskName)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handle("GET /config", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sup.DumpConfig())
+	})
+	handle("POST /state/export", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(sup.Handoff())
+	})
+	handle("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		stats := sup.Stats()
+		if raw := r.URL.Query().Get("window"); raw != "" {
+			window, err := time.ParseDuration(raw)
+			if err != nil || window <= 0 {
+				http.Error(w, "window must be a positive duration such as 15m", http.StatusBadRequest)
+				return
+			}
+			for taskName, st := range stats {
+				st.SuccessRate = nil
+				if rate := sup.SuccessRate(taskName, window); !math.IsNaN(rate) {
+					st.SuccessRate = &rate
+				}
+				stats[taskName] = st
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+	})
+	handle("GET /report/tasks.csv", taskReportHandler)
+	handle("GET /dead-letter", deadLetterListHandler)
+	handle("POST /dead-letter/{task_id}/replay", deadLetterReplayHandler(workflowClient))
+	if getEnv("METRICS_ENABLED", "false") == "true" {
+		handle("GET /metrics", metricsHandler(sup))
+	}
+	return mux
+}
+
+// maxCallbackBodyBytes caps the size of /callback bodies, which anyone holding
+// a callback token can send.
+const maxCallbackBodyBytes = 64 << 10
+
+// callbackHandler completes the task awaiting the token in the path. An
+// optional JSON object body, of at most maxCallbackBodyBytes, becomes the task
+// output.
+func callbackHandler(taskClient *client.TaskResourceApiService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.PathValue("token")
+		var output map[string]interface{}
+		r.Body = http.MaxBytesReader(w, r.Body, maxCallbackBodyBytes)
+		if err := json.NewDecoder(r.Body).Decode(&output); err != nil && err != io.EOF {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, fmt.Sprintf("Request body exceeds %d bytes", maxCallbackBodyBytes), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		pending, ok := callbacks.take(token)
+		if !ok {
+			http.Error(w, "Unknown or expired callback token", http.StatusNotFound)
+			return
+		}
+
+		if _, err := completeTask(r.Context(), taskClient, pending.TaskID, pending.WorkflowID, output); err != nil {
+			callbacks.restore(token, pending)
+			log.Printf("Admin: failed to complete task %s: %v", pending.TaskID, err)
+			http.Error(w, "Failed to complete task", http.StatusBadGateway)
+			return
+		}
+		log.Printf("Admin: completed task %s of workflow %s via callback", pending.TaskID, pending.WorkflowID)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"task_id":     pending.TaskID,
+			"workflow_id": pending.WorkflowID,
+			"status":      string(model.CompletedTask),
+		})
+	}
+}
+
+// scaleBatchSizesHandler scales every batch size by the factor query
+// parameter, e.g. POST /batch-size/scale?factor=1.5, and returns the new sizes.
+func scaleBatchSizesHandler(sup *supervisor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		factor, err := strconv.ParseFloat(r.URL.Query().Get("factor"), 64)
+		if err != nil {
+			http.Error(w, "factor must be a number", http.StatusBadRequest)
+			return
+		}
+		sizes, err := sup.ScaleBatchSizes(factor)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sizes)
+	}
+}
+
+// boostBatchSizeHandler raises the batch size of the task in the path by
+// ?delta= for ?duration=, e.g. to work off a backlog burst, then reverts it.
+// It answers the resulting runtime config of the task.
+func boostBatchSizeHandler(sup *supervisor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		taskName := r.PathValue("task_name")
+		if !sup.isRegistered(taskName) {
+			http.Error(w, "Unknown task", http.StatusNotFound)
+			return
+		}
+		delta, err := strconv.Atoi(r.URL.Query().Get("delta"))
+		if err != nil || delta < 1 {
+			http.Error(w, "delta must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		duration, err := time.ParseDuration(r.URL.Query().Get("duration"))
+		if err != nil || duration <= 0 {
+			http.Error(w, "duration must be a positive duration such as 5m", http.StatusBadRequest)
+			return
+		}
+		if err := sup.BoostBatchSize(taskName, delta, duration); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sup.DumpConfig().Tasks[taskName])
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCallbackHandlerBody(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "no body", wantStatus: http.StatusNotFound},
+		{name: "JSON object", body: `{"delivered": true}`, wantStatus: http.StatusNotFound},
+		{name: "invalid JSON", body: `{"delivered":`, wantStatus: http.StatusBadRequest},
+		{name: "over the cap", body: `{"note": "` + strings.Repeat("x", maxCallbackBodyBytes) + `"}`, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("POST /callback/{token}", callbackHandler(nil))
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest("POST", "/callback/unknown", strings.NewReader(tt.body)))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"runtime/metrics"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// allocMetrics are the runtime/metrics read before and after a sampled
+// handler execution. Reading them doesn't stop the world, unlike
+// runtime.ReadMemStats.
+var allocMetrics = []string{"/gc/heap/allocs:bytes", "/gc/heap/allocs:objects"}
+
+// withAllocSampling makes the supervisor measure the heap allocations of one
+// in every handler executions of each task; zero or less measures none.
+func withAllocSampling(every int) supervisorOption {
+	return func(s *supervisor) { s.allocSampleEvery = uint64(max(every, 0)) }
+}
+
+// measureAllocs runs fn on t and, for sampled executions, adds the heap
+// allocations made meanwhile to the stats of taskName. The counters are
+// process-wide, so a sample also counts what concurrent handlers and the
+// runtime allocated while fn ran.
+func (s *supervisor) measureAllocs(taskName string, fn model.ExecuteTaskFunction, t *model.Task) (interface{}, error) {
+	if s.allocSampleEvery == 0 {
+		return fn(t)
+	}
+	st := s.statsFor(taskName)
+	if st.allocTick.Add(1)%s.allocSampleEvery != 0 {
+		return fn(t)
+	}
+	samples := make([]metrics.Sample, len(allocMetrics))
+	for i, name := range allocMetrics {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+	bytes, objects := samples[0].Value.Uint64(), samples[1].Value.Uint64()
+	res, err := fn(t)
+	metrics.Read(samples)
+	st.allocSamples.Add(1)
+	st.allocBytes.Add(samples[0].Value.Uint64() - bytes)
+	st.allocObjects.Add(samples[1].Value.Uint64() - objects)
+	return res, err
+}
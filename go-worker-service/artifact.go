@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/conductor-sdk/conductor-go/sdk/client"
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// ArtifactUploader stores artifacts too large to inline in a task output.
+type ArtifactUploader interface {
+	// Upload stores data under a path derived from name and returns the
+	// storage path to reference it by.
+	Upload(ctx context.Context, name string, data []byte) (string, error)
+}
+
+// artifactUploader is the uploader attachArtifact uses, nil unless
+// ARTIFACT_STORAGE configures one.
+var artifactUploader ArtifactUploader
+
+// errNoArtifactUploader fails tasks attaching artifacts on a worker without
+// an uploader.
+var errNoArtifactUploader = errors.New("no artifact uploader configured, set ARTIFACT_STORAGE")
+
+// attachArtifact uploads data, named name, for the running task t and
+// returns the storage path the handler should put in its output instead of
+// the data. Artifacts are stored under <workflow id>/<task id>/<name>.
+// Without an uploader it fails the task for good.
+func attachArtifact(t *model.Task, name string, data []byte) (string, error) {
+	if artifactUploader == nil {
+		return "", model.NewNonRetryableError(errNoArtifactUploader)
+	}
+	if name == "" || path.Base(name) != name {
+		return "", model.NewNonRetryableError(fmt.Errorf("invalid artifact name %q", name))
+	}
+	p, err := artifactUploader.Upload(taskContext(t), path.Join(t.WorkflowInstanceId, t.TaskId, name), data)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload artifact %s: %w", name, err)
+	}
+	taskLogger(t).Printf("Attached artifact %s (%d bytes) at %s", name, len(data), p)
+	return p, nil
+}
+
+// artifactClient uploads artifacts to the URIs handed out by Conductor.
+var artifactClient = &http.Client{Timeout: time.Minute}
+
+// conductorArtifactUploader uploads artifacts to the external payload storage
+// of the Conductor server, such as S3, through the pre-signed URIs it hands
+// out for task outputs. The server must have external payload storage
+// configured.
+type conductorArtifactUploader struct {
+	taskClient *client.TaskResourceApiService
+}
+
+func (u conductorArtifactUploader) Upload(ctx context.Context, name string, data []byte) (string, error) {
+	loc, _, err := u.taskClient.GetExternalStorageLocation1(ctx, name, "WRITE", "TASK_OUTPUT")
+	if err != nil {
+		return "", fmt.Errorf("failed to get storage location: %w", err)
+	}
+	if loc.Uri == "" {
+		return "", model.NewNonRetryableError(errors.New("no external payload storage configured on the Conductor server"))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, loc.Uri, bytes.NewReader(data))
+	if err != nil {
+		return "", model.NewNonRetryableError(fmt.Errorf("invalid storage location: %w", err))
+	}
+	resp, err := artifactClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return "", &httpStatusError{Service: "artifact storage", StatusCode: resp.StatusCode}
+	}
+	return loc.Path, nil
+}
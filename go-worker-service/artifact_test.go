@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// stubUploader stores artifacts in memory, or fails with err.
+type stubUploader struct {
+	stored map[string][]byte
+	err    error
+}
+
+func (u *stubUploader) Upload(_ context.Context, name string, data []byte) (string, error) {
+	if u.err != nil {
+		return "", u.err
+	}
+	u.stored[name] = data
+	return "s3://artifacts/" + name, nil
+}
+
+func TestAttachArtifact(t *testing.T) {
+	tests := []struct {
+		name         string
+		uploader     ArtifactUploader
+		artifact     string
+		wantRef      string
+		wantErr      bool
+		wantTerminal bool
+		wantCause    error
+	}{
+		{name: "stored reference in the output", uploader: &stubUploader{stored: map[string][]byte{}}, artifact: "profile.json", wantRef: "s3://artifacts/wf1/t1/profile.json"},
+		{name: "storage error retried", uploader: &stubUploader{err: errTest}, artifact: "profile.json", wantErr: true, wantCause: errTest},
+		{name: "no uploader fails for good", artifact: "profile.json", wantErr: true, wantTerminal: true},
+		{name: "path in the name fails for good", uploader: &stubUploader{stored: map[string][]byte{}}, artifact: "../profile.json", wantErr: true, wantTerminal: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := artifactUploader
+			artifactUploader = tt.uploader
+			t.Cleanup(func() { artifactUploader = prev })
+
+			fn := func(t *model.Task) (interface{}, error) {
+				ref, err := attachArtifact(t, tt.artifact, []byte(`{"name":"ada"}`))
+				if err != nil {
+					return nil, err
+				}
+				return map[string]interface{}{"profile_ref": ref}, nil
+			}
+			res, err := withStatusMapping(defaultStatusMapper, fn)(&model.Task{TaskId: "t1", WorkflowInstanceId: "wf1", TaskDefName: "enrich_user_task"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want an error: %v", err, tt.wantErr)
+			}
+			if _, terminal := err.(*model.NonRetryableError); terminal != tt.wantTerminal {
+				t.Errorf("err = %v, want a *model.NonRetryableError: %v", err, tt.wantTerminal)
+			}
+			if tt.wantCause != nil && !errors.Is(err, tt.wantCause) {
+				t.Errorf("err = %v, want the storage error", err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := res.(map[string]interface{})["profile_ref"]; got != tt.wantRef {
+				t.Errorf("profile_ref = %v, want %s", got, tt.wantRef)
+			}
+			if got := string(tt.uploader.(*stubUploader).stored["wf1/t1/profile.json"]); got != `{"name":"ada"}` {
+				t.Errorf("stored %q, want the artifact", got)
+			}
+		})
+	}
+}
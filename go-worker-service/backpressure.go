@@ -0,0 +1,111 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// backpressureInterval is how often a backpressure gate is consulted.
+const backpressureInterval = time.Second
+
+// backpressureMaxLevel bounds the throttling of a backpressure gate: each
+// level halves the batch size, down to 1, and doubles the poll interval, so
+// polling slows down at most 2^backpressureMaxLevel times.
+const backpressureMaxLevel = 3
+
+// SetBackpressureGate consults overloaded every interval and, while it
+// reports true, e.g. while a queue the handlers feed is backed up, throttles
+// polling of every task one level further per check; each check that finds
+// it clear eases off one level, until the task polls as configured again.
+//
+// Unlike SetPollGate, which stops polling outright while a dependency is
+// unavailable, the backpressure gate keeps the worker pulling tasks, only
+// fewer and less often, so it degrades gracefully under load. Throttling is
+// applied over the configured batch size and poll interval, so config
+// reloads and boosts made meanwhile take effect, throttled, right away and
+// are kept when the task recovers.
+func (s *supervisor) SetBackpressureGate(overloaded func() bool, interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			busy := overloaded()
+			s.mu.Lock()
+			for taskName := range s.workers {
+				s.applyBackpressureLocked(taskName, busy)
+			}
+			s.mu.Unlock()
+		}
+	}()
+}
+
+// applyBackpressureLocked moves the throttling of taskName one level up when
+// busy, down otherwise. The caller must hold s.mu.
+func (s *supervisor) applyBackpressureLocked(taskName string, busy bool) {
+	level := s.backpressure[taskName]
+	switch {
+	case busy && level < backpressureMaxLevel:
+		level++
+	case !busy && level > 0:
+		level--
+	default:
+		return
+	}
+	batchSize := s.batchSizeLocked(taskName)
+	if level == 0 {
+		delete(s.backpressure, taskName)
+	} else {
+		s.backpressure[taskName] = level
+	}
+	if err := s.setBatchSizeLocked(taskName, batchSize); err != nil {
+		log.Printf("Supervisor: failed to set batch size of %s under backpressure: %v", taskName, err)
+	}
+	if err := s.applyPollIntervalLocked(taskName); err != nil {
+		log.Printf("Supervisor: failed to set poll interval of %s under backpressure: %v", taskName, err)
+	}
+	effective := s.runner.GetBatchSizeForTask(taskName)
+	interval, _ := s.runner.GetPollIntervalForTask(taskName)
+	if level == 0 {
+		log.Printf("Supervisor: backpressure cleared for %s, polling %d task(s) every %s", taskName, effective, interval)
+		return
+	}
+	log.Printf("Supervisor: backpressure level %d for %s, polling %d task(s) every %s", level, taskName, effective, interval)
+}
+
+// batchSizeLocked returns the batch size of taskName, boosts included, as
+// configured, i.e. before backpressure throttles it. The caller must hold
+// s.mu.
+func (s *supervisor) batchSizeLocked(taskName string) int {
+	return s.runner.GetBatchSizeForTask(taskName) + s.throttleDelta[taskName]
+}
+
+// setBatchSizeLocked sets the batch size of taskName, boosts included, to
+// size, of which the runner polls the share backpressure leaves at its
+// current level. The caller must hold s.mu.
+func (s *supervisor) setBatchSizeLocked(taskName string, size int) error {
+	throttled := size >> s.backpressure[taskName]
+	if size > 0 && throttled < 1 {
+		throttled = 1
+	}
+	if err := s.runner.SetBatchSize(taskName, throttled); err != nil {
+		return err
+	}
+	if size == throttled {
+		delete(s.throttleDelta, taskName)
+	} else {
+		s.throttleDelta[taskName] = size - throttled
+	}
+	return nil
+}
+
+// applyPollIntervalLocked sets the poll interval the runner uses for taskName
+// from its configured one, stretched by backpressure and idle backoff. The
+// caller must hold s.mu.
+func (s *supervisor) applyPollIntervalLocked(taskName string) error {
+	interval := s.pollIntervals[taskName] << s.backpressure[taskName]
+	if s.idleBackoff != nil {
+		interval = s.idleBackoff.stretch(interval, s.emptyPolls[taskName])
+	}
+	if cur, err := s.runner.GetPollIntervalForTask(taskName); err == nil && cur == interval {
+		return nil
+	}
+	return s.runner.SetPollIntervalForTask(taskName, interval)
+}
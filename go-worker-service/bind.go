@@ -0,0 +1,332 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+	"github.com/conductor-sdk/conductor-go/sdk/worker"
+)
+
+// inputBinder decodes task inputs into handler types, naming keys by their
+// json tags like the SDK's typed workers. Inputs of other schema versions go
+// through the binders registered with it for their version.
+var inputBinder = newVersionedBinder(worker.JSONBinder{})
+
+// bindInput decodes the input of t into dst, which must be a pointer. The raw
+// input is left untouched, so a handler can bind the same task more than
+// once: a polymorphic task can first bind a small header holding its type
+// field, then the full payload into the type that field selects. An input
+// that doesn't fit dst fails the task for good.
+func bindInput(t *model.Task, dst interface{}) error {
+	if c, ok := boundInputs.Load(t); ok {
+		return c.(*inputCache).bind(t, dst)
+	}
+	return bindTaskInput(t, dst)
+}
+
+func bindTaskInput(t *model.Task, dst interface{}) error {
+	if err := inputBinder.Bind(dst, t.InputData); err != nil {
+		return model.NewNonRetryableError(fmt.Errorf("input binding error for task %s: %w", t.TaskDefName, err))
+	}
+	return nil
+}
+
+// inputPath returns the value at a dotted path such as "user.address.zip" in
+// the input of t, without binding the whole input. Numeric segments index
+// into arrays, as in "users.0.name". It returns false when any segment is
+// missing or out of range.
+func inputPath(t *model.Task, path string) (interface{}, bool) {
+	var cur interface{} = t.InputData
+	for _, seg := range strings.Split(path, ".") {
+		switch v := cur.(type) {
+		case map[string]interface{}:
+			next, ok := v[seg]
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case []interface{}:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			cur = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// inputVersionKey is the task input key holding the version of the input
+// schema, e.g. {"_v": 2, ...}.
+const inputVersionKey = "_v"
+
+// versionedBinder picks the binder of a task input by its inputVersionKey, so
+// tasks still carrying the previous input shape keep binding while a
+// migration is in flight. A binder registered for an older version typically
+// maps its shape onto the current handler types.
+type versionedBinder struct {
+	current  worker.InputBinder
+	versions map[string]worker.InputBinder
+	// strict fails inputs of unregistered versions instead of binding them
+	// with current.
+	strict bool
+}
+
+func newVersionedBinder(current worker.InputBinder) *versionedBinder {
+	return &versionedBinder{current: current, versions: map[string]worker.InputBinder{}}
+}
+
+// Register makes b bind the inputs of version, e.g. "1". Register binders
+// before the workers start.
+func (b *versionedBinder) Register(version string, binder worker.InputBinder) {
+	b.versions[version] = binder
+}
+
+// Bind decodes src with the binder of its version. Inputs without a version,
+// and, unless strict, of versions without a binder, use the current binder.
+func (b *versionedBinder) Bind(dst interface{}, src map[string]interface{}) error {
+	raw, ok := src[inputVersionKey]
+	if !ok {
+		return b.current.Bind(dst, src)
+	}
+	// JSON numbers arrive as float64; fmt renders whole ones without a fraction
+	version := fmt.Sprint(raw)
+	if binder, ok := b.versions[version]; ok {
+		return binder.Bind(dst, src)
+	}
+	if b.strict {
+		return fmt.Errorf("unknown input version %q", version)
+	}
+	return b.current.Bind(dst, src)
+}
+
+// renamingBinder binds like next after renaming the top-level input keys of
+// an older schema version to their current names, e.g. enterprise_name to
+// entp_name. Keys already carrying the current name win over renamed ones.
+type renamingBinder struct {
+	renames map[string]string
+	next    worker.InputBinder
+}
+
+func (b renamingBinder) Bind(dst interface{}, src map[string]interface{}) error {
+	out := make(map[string]interface{}, len(src))
+	for k, v := range src {
+		if name, ok := b.renames[k]; ok {
+			if _, taken := src[name]; !taken {
+				out[name] = v
+			}
+			continue
+		}
+		out[k] = v
+	}
+	return b.next.Bind(dst, out)
+}
+
+// parseLegacyInputKeys parses a comma separated list of version:old=new key
+// renames, e.g. "1:enterprise_name=entp_name,1:username=user_name", into the
+// renames of each input version.
+func parseLegacyInputKeys(spec string) (map[string]map[string]string, error) {
+	renames := map[string]map[string]string{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		version, rename, ok := strings.Cut(entry, ":")
+		old, name, ok2 := strings.Cut(rename, "=")
+		version, old, name = strings.TrimSpace(version), strings.TrimSpace(old), strings.TrimSpace(name)
+		if !ok || !ok2 || version == "" || old == "" || name == "" {
+			return nil, fmt.Errorf("malformed entry %q: want version:old=new", entry)
+		}
+		if renames[version] == nil {
+			renames[version] = map[string]string{}
+		}
+		renames[version][old] = name
+	}
+	return renames, nil
+}
+
+// InputMiddleware is cross-cutting logic, such as auth or tenant checks, that
+// runs before a handler with the task and a decode accessor binding its input
+// like bindInput. It calls next to run the handler, or returns without calling
+// it to short-circuit, e.g. with a model.NewNonRetryableError to fail the
+// task for good.
+type InputMiddleware func(t *model.Task, decode func(dst interface{}) error, next func() (interface{}, error)) (interface{}, error)
+
+// withInputMiddleware wraps a worker handler with mws, the first one
+// outermost. Each input type is bound once per task: later binds of the same
+// type, by the other middlewares or the handler's own bindInput, get a copy of
+// the first result, sharing its maps, slices and pointers.
+func withInputMiddleware(fn func(*model.Task) (interface{}, error), mws ...InputMiddleware) func(*model.Task) (interface{}, error) {
+	return func(t *model.Task) (interface{}, error) {
+		if _, loaded := boundInputs.LoadOrStore(t, &inputCache{byType: map[reflect.Type]reflect.Value{}}); !loaded {
+			defer boundInputs.Delete(t)
+		}
+		decode := func(dst interface{}) error { return bindInput(t, dst) }
+		var run func(i int) (interface{}, error)
+		run = func(i int) (interface{}, error) {
+			if i == len(mws) {
+				return fn(t)
+			}
+			return mws[i](t, decode, func() (interface{}, error) { return run(i + 1) })
+		}
+		return run(0)
+	}
+}
+
+// enterpriseInput is the part of a task input naming its enterprise.
+type enterpriseInput struct {
+	EntpName string `json:"entp_name"`
+}
+
+// enterpriseAllowlist is a tenant check failing tasks for good whose entp_name
+// input names an enterprise missing from allowed. Tasks without entp_name
+// pass.
+func enterpriseAllowlist(allowed map[string]bool) InputMiddleware {
+	return func(t *model.Task, decode func(dst interface{}) error, next func() (interface{}, error)) (interface{}, error) {
+		var in enterpriseInput
+		if err := decode(&in); err != nil {
+			return nil, err
+		}
+		if in.EntpName != "" && !allowed[in.EntpName] {
+			return nil, model.NewNonRetryableError(fmt.Errorf("enterprise '%s' is not served by this worker", in.EntpName))
+		}
+		return next()
+	}
+}
+
+// boundInputs holds the inputCache of each task running under
+// withInputMiddleware or withAuditLog.
+var boundInputs sync.Map
+
+// inputCache holds the inputs of a task bound so far, by type.
+type inputCache struct {
+	mu     sync.Mutex
+	byType map[reflect.Type]reflect.Value
+}
+
+func (c *inputCache) bind(t *model.Task, dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return bindTaskInput(t, dst)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	typ := rv.Type().Elem()
+	if v, ok := c.byType[typ]; ok {
+		rv.Elem().Set(v)
+		return nil
+	}
+	if err := bindTaskInput(t, dst); err != nil {
+		return err
+	}
+	v := reflect.New(typ).Elem()
+	v.Set(rv.Elem())
+	c.byType[typ] = v
+	return nil
+}
+
+// snakeCaseBinder binds like next, additionally filling struct fields without
+// a json tag name from the snake_case form of their name, e.g. EntpName from
+// entp_name and UserID from user_id. Tagged fields and keys already matching
+// a field name are bound as before.
+type snakeCaseBinder struct {
+	next worker.InputBinder
+}
+
+func (b snakeCaseBinder) Bind(dst interface{}, src map[string]interface{}) error {
+	if m, ok := snakeCaseKeys(reflect.TypeOf(dst), src).(map[string]interface{}); ok {
+		src = m
+	}
+	return b.next.Bind(dst, src)
+}
+
+// snakeCaseKeys returns v, the JSON form of a value of type t, with the
+// snake_case keys of untagged struct fields, at any depth, copied to the
+// field names. v itself is left untouched.
+func snakeCaseKeys(t reflect.Type, v interface{}) interface{} {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return v
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		out := make(map[string]interface{}, len(m))
+		for k, val := range m {
+			out[k] = val
+		}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			key := f.Name
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			if name := strings.Split(tag, ",")[0]; name != "" {
+				key = name
+			} else if _, ok := m[key]; !ok {
+				if val, ok := m[snakeCase(f.Name)]; ok {
+					out[key] = val
+				}
+			}
+			if val, ok := out[key]; ok {
+				out[key] = snakeCaseKeys(f.Type, val)
+			}
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		s, ok := v.([]interface{})
+		if !ok {
+			return v
+		}
+		out := make([]interface{}, len(s))
+		for i, val := range s {
+			out[i] = snakeCaseKeys(t.Elem(), val)
+		}
+		return out
+	case reflect.Map:
+		m, ok := v.(map[string]interface{})
+		if !ok || t.Key().Kind() != reflect.String {
+			return v
+		}
+		out := make(map[string]interface{}, len(m))
+		for k, val := range m {
+			out[k] = snakeCaseKeys(t.Elem(), val)
+		}
+		return out
+	}
+	return v
+}
+
+// snakeCase converts a Go field name to snake_case, keeping initialisms
+// together: UserID becomes user_id and HTTPPort http_port.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (!unicode.IsUpper(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+	"github.com/conductor-sdk/conductor-go/sdk/worker"
+)
+
+func TestBindInput(t *testing.T) {
+	type header struct {
+		Type string `json:"type"`
+	}
+	type user struct {
+		Type     string   `json:"type"`
+		UserName string   `json:"user_name"`
+		Age      int      `json:"age"`
+		Roles    []string `json:"roles"`
+	}
+	tests := []struct {
+		name         string
+		input        map[string]interface{}
+		dst          interface{}
+		want         interface{}
+		wantTerminal bool
+	}{
+		{
+			name:  "header only",
+			input: map[string]interface{}{"type": "user", "user_name": "ada"},
+			dst:   &header{},
+			want:  &header{Type: "user"},
+		},
+		{
+			name:  "full payload",
+			input: map[string]interface{}{"type": "user", "user_name": "ada", "age": 36.0, "roles": []interface{}{"admin"}},
+			dst:   &user{},
+			want:  &user{Type: "user", UserName: "ada", Age: 36, Roles: []string{"admin"}},
+		},
+		{
+			name:  "missing keys left zero",
+			input: map[string]interface{}{"user_name": "ada"},
+			dst:   &user{},
+			want:  &user{UserName: "ada"},
+		},
+		{
+			name:         "mismatched type fails for good",
+			input:        map[string]interface{}{"age": "thirty"},
+			dst:          &user{},
+			wantTerminal: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &model.Task{TaskDefName: "create_user_task", InputData: tt.input}
+			before := fmt.Sprint(tt.input)
+			err := bindInput(task, tt.dst)
+			if _, terminal := err.(*model.NonRetryableError); terminal != tt.wantTerminal {
+				t.Fatalf("err = %v, want a *model.NonRetryableError: %v", err, tt.wantTerminal)
+			}
+			if tt.want != nil && !reflect.DeepEqual(tt.dst, tt.want) {
+				t.Errorf("bound %+v, want %+v", tt.dst, tt.want)
+			}
+			if got := fmt.Sprint(task.InputData); got != before {
+				t.Errorf("input changed to %s, want %s", got, before)
+			}
+			// The raw input stays bindable, e.g. into a header after the payload
+			var h header
+			wantType, _ := tt.input["type"].(string)
+			if err := bindInput(task, &h); err != nil || h.Type != wantType {
+				t.Errorf("header = %+v, %v after binding %T", h, err, tt.dst)
+			}
+		})
+	}
+}
+
+func TestInputPath(t *testing.T) {
+	input := map[string]interface{}{
+		"user": map[string]interface{}{
+			"name":    "ada",
+			"address": map[string]interface{}{"zip": "10115"},
+			"manager": nil,
+		},
+		"users": []interface{}{map[string]interface{}{"name": "grace"}},
+		"id":    7.0,
+	}
+	tests := []struct {
+		name   string
+		path   string
+		want   interface{}
+		wantOK bool
+	}{
+		{name: "top level", path: "id", want: 7.0, wantOK: true},
+		{name: "nested", path: "user.address.zip", want: "10115", wantOK: true},
+		{name: "nested object", path: "user.address", want: map[string]interface{}{"zip": "10115"}, wantOK: true},
+		{name: "explicit null", path: "user.manager", wantOK: true},
+		{name: "array index", path: "users.0.name", want: "grace", wantOK: true},
+		{name: "missing leaf", path: "user.address.city"},
+		{name: "missing parent", path: "account.type"},
+		{name: "through a scalar", path: "user.name.first"},
+		{name: "through null", path: "user.manager.name"},
+		{name: "index out of range", path: "users.1.name"},
+		{name: "negative index", path: "users.-1.name"},
+		{name: "non-numeric index", path: "users.first.name"},
+		{name: "empty segment", path: "user..name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := inputPath(&model.Task{InputData: input}, tt.path)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("inputPath(%q) = %v, %v, want %v, %v", tt.path, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestVersionedBinder(t *testing.T) {
+	type account struct {
+		EntpName string `json:"entp_name"`
+		UserName string `json:"user_name"`
+	}
+	v1 := renamingBinder{renames: map[string]string{"enterprise_name": "entp_name", "username": "user_name"}, next: worker.JSONBinder{}}
+	tests := []struct {
+		name    string
+		strict  bool
+		input   map[string]interface{}
+		want    account
+		wantErr bool
+	}{
+		{name: "no version", input: map[string]interface{}{"entp_name": "acme", "user_name": "ada"}, want: account{EntpName: "acme", UserName: "ada"}},
+		{name: "registered version as a JSON number", input: map[string]interface{}{"_v": 1.0, "enterprise_name": "acme", "username": "ada"}, want: account{EntpName: "acme", UserName: "ada"}},
+		{name: "registered version as a string", input: map[string]interface{}{"_v": "1", "enterprise_name": "acme"}, want: account{EntpName: "acme"}},
+		{name: "current name wins over the renamed key", input: map[string]interface{}{"_v": 1.0, "enterprise_name": "old", "entp_name": "acme"}, want: account{EntpName: "acme"}},
+		{name: "mixed old and current keys", input: map[string]interface{}{"_v": 1.0, "enterprise_name": "acme", "user_name": "ada"}, want: account{EntpName: "acme", UserName: "ada"}},
+		{name: "current version", input: map[string]interface{}{"_v": 2.0, "entp_name": "acme", "enterprise_name": "old"}, want: account{EntpName: "acme"}},
+		{name: "unknown version uses the current binder", input: map[string]interface{}{"_v": 3.0, "entp_name": "acme"}, want: account{EntpName: "acme"}},
+		{name: "unknown version fails when strict", strict: true, input: map[string]interface{}{"_v": 3.0, "entp_name": "acme"}, wantErr: true},
+		{name: "no version passes when strict", strict: true, input: map[string]interface{}{"entp_name": "acme"}, want: account{EntpName: "acme"}},
+		{name: "fractional version unknown", strict: true, input: map[string]interface{}{"_v": 1.5, "enterprise_name": "acme"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newVersionedBinder(worker.JSONBinder{})
+			b.Register("1", v1)
+			b.strict = tt.strict
+			var got account
+			err := b.Bind(&got, tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want an error: %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("bound %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLegacyInputKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    map[string]map[string]string
+		wantErr bool
+	}{
+		{name: "empty", spec: "", want: map[string]map[string]string{}},
+		{
+			name: "several versions",
+			spec: "1:enterprise_name=entp_name, 1:username=user_name,2:entp=entp_name",
+			want: map[string]map[string]string{
+				"1": {"enterprise_name": "entp_name", "username": "user_name"},
+				"2": {"entp": "entp_name"},
+			},
+		},
+		{name: "spaces and empty entries ignored", spec: " 1 : a = b ,,", want: map[string]map[string]string{"1": {"a": "b"}}},
+		{name: "missing version", spec: "a=b", wantErr: true},
+		{name: "missing rename", spec: "1:a", wantErr: true},
+		{name: "empty new name", spec: "1:a=", wantErr: true},
+		{name: "empty version", spec: ":a=b", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLegacyInputKeys(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want an error: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseLegacyInputKeys(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithInputMiddleware(t *testing.T) {
+	type tagged struct {
+		Tags map[string]interface{} `json:"tags"`
+	}
+	var calls []string
+	// pass records its name and hands the task on, marking the tags input it
+	// binds so later binds can be told apart from fresh ones
+	pass := func(name string) InputMiddleware {
+		return func(t *model.Task, decode func(dst interface{}) error, next func() (interface{}, error)) (interface{}, error) {
+			calls = append(calls, name)
+			var in tagged
+			if err := decode(&in); err != nil {
+				return nil, err
+			}
+			if in.Tags != nil {
+				in.Tags[name] = true
+			}
+			return next()
+		}
+	}
+	reject := func(name string, err error) InputMiddleware {
+		return func(*model.Task, func(dst interface{}) error, func() (interface{}, error)) (interface{}, error) {
+			calls = append(calls, name)
+			return map[string]interface{}{"rejected_by": name}, err
+		}
+	}
+	tests := []struct {
+		name         string
+		mws          []InputMiddleware
+		wantCalls    []string
+		wantOut      interface{}
+		wantTerminal bool
+		wantTags     []string
+	}{
+		{name: "no middleware", wantCalls: []string{"handler"}, wantOut: "done"},
+		{name: "run in order", mws: []InputMiddleware{pass("auth"), pass("tenant")}, wantCalls: []string{"auth", "tenant", "handler"}, wantOut: "done", wantTags: []string{"auth", "tenant"}},
+		{name: "first short-circuits", mws: []InputMiddleware{reject("auth", nil), pass("tenant")}, wantCalls: []string{"auth"}, wantOut: map[string]interface{}{"rejected_by": "auth"}},
+		{
+			name:         "later short-circuits with a terminal error",
+			mws:          []InputMiddleware{pass("auth"), reject("tenant", model.NewNonRetryableError(errTest))},
+			wantCalls:    []string{"auth", "tenant"},
+			wantTerminal: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			var handlerTags map[string]interface{}
+			fn := withInputMiddleware(func(task *model.Task) (interface{}, error) {
+				calls = append(calls, "handler")
+				var in tagged
+				if err := bindInput(task, &in); err != nil {
+					return nil, err
+				}
+				handlerTags = in.Tags
+				return "done", nil
+			}, tt.mws...)
+			task := &model.Task{InputData: map[string]interface{}{"tags": map[string]interface{}{}}}
+			out, err := fn(task)
+
+			if !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+			if _, terminal := err.(*model.NonRetryableError); terminal != tt.wantTerminal || err != nil && !terminal {
+				t.Fatalf("err = %v, want a *model.NonRetryableError: %v", err, tt.wantTerminal)
+			}
+			if err == nil && !reflect.DeepEqual(out, tt.wantOut) {
+				t.Errorf("out = %v, want %v", out, tt.wantOut)
+			}
+			// The handler gets the input the middlewares bound, not a fresh bind
+			for _, name := range tt.wantTags {
+				if handlerTags[name] != true {
+					t.Errorf("handler tags %v missing %s", handlerTags, name)
+				}
+			}
+			if _, ok := boundInputs.Load(task); ok {
+				t.Error("bound inputs of the task kept after it ran")
+			}
+		})
+	}
+}
+
+func TestEnterpriseAllowlist(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        map[string]interface{}
+		wantRun      bool
+		wantTerminal bool
+	}{
+		{name: "allowed enterprise", input: map[string]interface{}{"entp_name": "acme"}, wantRun: true},
+		{name: "other enterprise", input: map[string]interface{}{"entp_name": "globex"}, wantTerminal: true},
+		{name: "no enterprise", input: map[string]interface{}{"user_name": "ada"}, wantRun: true},
+		{name: "empty enterprise", input: map[string]interface{}{"entp_name": ""}, wantRun: true},
+		{name: "malformed enterprise", input: map[string]interface{}{"entp_name": 42.0}, wantTerminal: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ran := false
+			fn := withInputMiddleware(func(*model.Task) (interface{}, error) {
+				ran = true
+				return nil, nil
+			}, enterpriseAllowlist(map[string]bool{"acme": true}))
+			_, err := fn(&model.Task{TaskDefName: "create_enterprise_task", InputData: tt.input})
+			if ran != tt.wantRun {
+				t.Errorf("handler ran: %v, want %v", ran, tt.wantRun)
+			}
+			if _, terminal := err.(*model.NonRetryableError); terminal != tt.wantTerminal {
+				t.Errorf("err = %v, want a *model.NonRetryableError: %v", err, tt.wantTerminal)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// releaseCallbackSeconds is how long Conductor waits before handing a task
+// released by an exhausted budget to another worker.
+const releaseCallbackSeconds = 1
+
+// taskBudget caps the number of tasks a worker executes before it shuts down.
+type taskBudget struct {
+	limit   int64
+	started atomic.Int64
+	done    atomic.Int64
+	drained chan struct{}
+	once    sync.Once
+}
+
+// SetMaxTasks makes the supervisor execute at most n tasks of taskName. Once
+// the n-th task has been handed to its handler, polling for taskName stops;
+// the returned channel is closed when that task has finished. Tasks the SDK
+// had already polled beyond the budget are released back to Conductor as
+// IN_PROGRESS so another worker picks them up. It must be called before the
+// worker is registered.
+func (s *supervisor) SetMaxTasks(taskName string, n int) <-chan struct{} {
+	b := &taskBudget{limit: int64(n), drained: make(chan struct{})}
+	s.mu.Lock()
+	s.budgets[taskName] = b
+	s.mu.Unlock()
+	return b.drained
+}
+
+func (s *supervisor) budgetFor(taskName string) *taskBudget {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.budgets[taskName]
+}
+
+// withBudget wraps fn so that it only runs within the budget of taskName, if any.
+func (s *supervisor) withBudget(taskName string, fn model.ExecuteTaskFunction) model.ExecuteTaskFunction {
+	return func(t *model.Task) (interface{}, error) {
+		b := s.budgetFor(taskName)
+		if b == nil {
+			return fn(t)
+		}
+		n := b.started.Add(1)
+		if n > b.limit {
+			result := model.NewTaskResultFromTask(t)
+			result.Status = model.InProgressTask
+			result.CallbackAfterSeconds = releaseCallbackSeconds
+			return result, nil
+		}
+		if n == b.limit {
+			log.Printf("Supervisor: %s reached its budget of %d task(s), stopping polling", taskName, b.limit)
+			go s.Shutdown(taskName)
+		}
+		defer func() {
+			if b.done.Add(1) == b.limit {
+				b.once.Do(func() { close(b.drained) })
+			}
+		}()
+		return fn(t)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// welcomeEmailCallbackSeconds is how long Conductor keeps a welcome email task
+// IN_PROGRESS before handing it back to a worker, which then keeps waiting.
+const welcomeEmailCallbackSeconds = 300
+
+// defaultCallbackTTL is how long a callback token stays valid without
+// CALLBACK_TOKEN_TTL_MS.
+const defaultCallbackTTL = 24 * time.Hour
+
+// errCallbackExpired is returned by tokenFor for a task whose callback token
+// expired before the callback arrived.
+var errCallbackExpired = errors.New("callback token expired")
+
+// pendingCallback identifies a task left IN_PROGRESS until a callback arrives.
+type pendingCallback struct {
+	TaskID     string
+	WorkflowID string
+	IssuedAt   time.Time
+}
+
+// callbackStore maps callback tokens to the tasks awaiting them. It is process
+// local: tokens are lost on restart, in which case the redelivered task is
+// issued a new token.
+//
+// Tokens expire ttl after they are issued: the callback endpoint no longer
+// accepts them, and the next delivery of the task fails it. Expired tokens are
+// dropped from memory once the task had time to be delivered again.
+type callbackStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	byToken map[string]pendingCallback
+	byTask  map[string]string
+}
+
+func newCallbackStore(ttl time.Duration) *callbackStore {
+	return &callbackStore{
+		ttl:     ttl,
+		now:     time.Now,
+		byToken: make(map[string]pendingCallback),
+		byTask:  make(map[string]string),
+	}
+}
+
+var callbacks = newCallbackStore(defaultCallbackTTL)
+
+func (s *callbackStore) expired(p pendingCallback) bool {
+	return s.now().Sub(p.IssuedAt) >= s.ttl
+}
+
+// sweepLocked drops the tokens expired for twice the ttl, whose tasks were
+// most likely failed or abandoned. s.mu must be held.
+func (s *callbackStore) sweepLocked() {
+	for token, p := range s.byToken {
+		if s.now().Sub(p.IssuedAt) >= 2*s.ttl {
+			delete(s.byToken, token)
+			delete(s.byTask, p.TaskID)
+		}
+	}
+}
+
+// tokenFor returns the callback token of t, issuing one on first use. It
+// returns errCallbackExpired, forgetting the token, once the token of t
+// expired.
+func (s *callbackStore) tokenFor(t *model.Task) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepLocked()
+	if token, ok := s.byTask[t.TaskId]; ok {
+		if p, ok := s.byToken[token]; ok && s.expired(p) {
+			delete(s.byToken, token)
+			delete(s.byTask, t.TaskId)
+			return "", errCallbackExpired
+		}
+		return token, nil
+	}
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate callback token: %w", err)
+	}
+	token := hex.EncodeToString(b[:])
+	s.byToken[token] = pendingCallback{TaskID: t.TaskId, WorkflowID: t.WorkflowInstanceId, IssuedAt: s.now()}
+	s.byTask[t.TaskId] = token
+	return token, nil
+}
+
+// take removes and returns the task awaiting token, unless token expired.
+func (s *callbackStore) take(token string) (pendingCallback, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.byToken[token]
+	if !ok || s.expired(p) {
+		return pendingCallback{}, false
+	}
+	delete(s.byToken, token)
+	delete(s.byTask, p.TaskID)
+	return p, true
+}
+
+// restore puts back a token whose completion could not be delivered.
+func (s *callbackStore) restore(token string, p pendingCallback) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byToken[token] = p
+	s.byTask[p.TaskID] = token
+}
+
+func init() {
+	handlers.Register("send_welcome_email_task", sendWelcomeEmailWorker)
+	handlers.After("send_welcome_email_task", "create_user_task")
+}
+
+// sendWelcomeEmailWorker implements the 'send_welcome_email_task'. It hands the
+// email off and leaves the task IN_PROGRESS until the delivery is confirmed on
+// the admin server's /callback/{token} endpoint.
+func sendWelcomeEmailWorker(t *model.Task) (interface{}, error) {
+	logger := taskLogger(t)
+	userName, ok := t.InputData["user_name"].(string)
+	if !ok || userName == "" {
+		return nil, model.NewNonRetryableError(fmt.Errorf("missing user_name in task input"))
+	}
+	token, err := callbacks.tokenFor(t)
+	if errors.Is(err, errCallbackExpired) {
+		return nil, model.NewNonRetryableError(fmt.Errorf("delivery of the welcome email to '%s' not confirmed within %s", userName, callbacks.ttl))
+	}
+	if err != nil {
+		return nil, err
+	}
+	logger.Printf("Worker 3: Welcome email for '%s' sent, awaiting delivery confirmation on /callback/%s", userName, token)
+
+	result := model.NewTaskResultFromTask(t)
+	result.Status = model.InProgressTask
+	result.CallbackAfterSeconds = welcomeEmailCallbackSeconds
+	result.OutputData = map[string]interface{}{"callback_token": token}
+	return result, nil
+}
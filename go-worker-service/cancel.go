@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// taskContexts maps the id of each task running under withCancellation to its
+// *taskCancel.
+var taskContexts sync.Map
+
+type taskCancel struct {
+	taskName string
+	ctx      context.Context
+	cancel   context.CancelCauseFunc
+}
+
+// Causes of the cancellation of a task context.
+var (
+	// errCancelledByOperator aborts a task for good (see cancelTask).
+	errCancelledByOperator = errors.New("task cancelled by operator")
+	// errCancelledByPause stops a task for a maintenance pause (see
+	// PauseWithCancel); Conductor retries it.
+	errCancelledByPause = errors.New("task cancelled by pause")
+)
+
+// taskContext returns the context of the running task t, cancelled when an
+// operator aborts the task with cancelTask or when its handler timeout passes.
+// Handlers should pass it to blocking calls such as database queries.
+func taskContext(t *model.Task) context.Context {
+	if v, ok := taskContexts.Load(t.TaskId); ok {
+		return v.(*taskCancel).ctx
+	}
+	return context.Background()
+}
+
+// cancelTask cancels the context of the running task taskID. It reports false
+// if no such task is running. Handlers that ignore their context keep running.
+func cancelTask(taskID string) bool {
+	v, ok := taskContexts.Load(taskID)
+	if !ok {
+		return false
+	}
+	v.(*taskCancel).cancel(errCancelledByOperator)
+	return true
+}
+
+// cancelTasksOf cancels the context of every running task of taskName for a
+// pause and returns how many were cancelled.
+func cancelTasksOf(taskName string) int {
+	n := 0
+	taskContexts.Range(func(_, v interface{}) bool {
+		if tc := v.(*taskCancel); tc.taskName == taskName {
+			tc.cancel(errCancelledByPause)
+			n++
+		}
+		return true
+	})
+	return n
+}
+
+// withCancellation wraps a worker handler so that it can be aborted with
+// cancelTask. A handler failing after being cancelled fails the task with a
+// terminal error, so Conductor doesn't retry a task an operator aborted. A
+// handler failing after being cancelled by PauseWithCancel fails the task
+// with a retryable error instead, so it runs again once the pause is over.
+//
+// The task context also carries the deadline of handlerTimeout, derived from
+// the task definition's response timeout. A handler failing after its deadline
+// fails the task with a retryable error, as Conductor's own timeout would.
+func withCancellation(fn func(*model.Task) (interface{}, error)) func(*model.Task) (interface{}, error) {
+	return func(t *model.Task) (interface{}, error) {
+		base, cancel := context.WithCancelCause(context.Background())
+		ctx, stop := base, context.CancelFunc(func() {})
+		if timeout := handlerTimeout(t.TaskDefName); timeout > 0 {
+			ctx, stop = context.WithTimeout(base, timeout)
+		}
+		taskContexts.Store(t.TaskId, &taskCancel{taskName: t.TaskDefName, ctx: ctx, cancel: cancel})
+		defer func() {
+			taskContexts.Delete(t.TaskId)
+			stop()
+			cancel(nil)
+		}()
+		res, err := fn(t)
+		if err != nil && ctx.Err() != nil {
+			switch cause := context.Cause(ctx); {
+			case errors.Is(cause, errCancelledByPause):
+				// Drop the handler's error chain, which may mark it terminal
+				return res, fmt.Errorf("%w: %v", errCancelledByPause, err)
+			case errors.Is(cause, errCancelledByOperator):
+				return res, model.NewNonRetryableError(fmt.Errorf("%w: %w", errCancelledByOperator, err))
+			default:
+				return res, fmt.Errorf("task exceeded its %s handler timeout: %w", handlerTimeout(t.TaskDefName), err)
+			}
+		}
+		return res, err
+	}
+}
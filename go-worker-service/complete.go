@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/conductor-sdk/conductor-go/sdk/client"
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// completeTask completes the task taskID of workflowID from outside the task
+// runner, e.g. on an operator action for a task a handler left IN_PROGRESS.
+// output goes through the conversion the runner applies to handler outputs:
+// a *model.TaskResult is sent as is, with taskID and workflowID filled in
+// where it leaves them empty, anything else becomes the output map of a
+// COMPLETED result. The result sent is returned.
+func completeTask(ctx context.Context, taskClient *client.TaskResourceApiService, taskID, workflowID string, output interface{}) (*model.TaskResult, error) {
+	t := &model.Task{TaskId: taskID, WorkflowInstanceId: workflowID}
+	result, err := model.GetTaskResultFromTaskExecutionOutput(t, output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert the output of task %s: %w", taskID, err)
+	}
+	if result.TaskId == "" {
+		result.TaskId = taskID
+	}
+	if result.WorkflowInstanceId == "" {
+		result.WorkflowInstanceId = workflowID
+	}
+	if generatedWorkerID != "" {
+		workerIDDecorator(generatedWorkerID)(result)
+	}
+	if _, _, err := taskClient.UpdateTask(ctx, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
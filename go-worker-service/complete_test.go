@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/conductor-sdk/conductor-go/sdk/client"
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+func TestCompleteTaskMatchesRunner(t *testing.T) {
+	type account struct {
+		UserID int    `json:"user_id"`
+		Email  string `json:"email,omitempty"`
+	}
+	tests := []struct {
+		name string
+		// output returns the output, fresh for each of the runner and
+		// completeTask as a *model.TaskResult is sent as is
+		output func() interface{}
+	}{
+		{name: "map", output: func() interface{} { return map[string]interface{}{"user_id": 1, "email": "ada@example.com"} }},
+		{name: "struct", output: func() interface{} { return account{UserID: 1} }},
+		{name: "struct pointer", output: func() interface{} { return &account{UserID: 1, Email: "ada@example.com"} }},
+		{name: "nil", output: func() interface{} { return nil }},
+		{
+			name: "task result",
+			output: func() interface{} {
+				return &model.TaskResult{Status: model.FailedWithTerminalErrorTask, ReasonForIncompletion: "rejected", OutputData: map[string]interface{}{"approved": false}}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeConductor(t)
+			sup := newTestSupervisor(t, f)
+			f.enqueue("parity_task", model.Task{TaskId: "t1", WorkflowInstanceId: "wf1", TaskDefName: "parity_task", TaskType: "parity_task"})
+			if err := sup.RegisterWorker(testWorker("parity_task", func(*model.Task) (interface{}, error) { return tt.output(), nil })); err != nil {
+				t.Fatalf("RegisterWorker: %v", err)
+			}
+			viaRunner := f.waitUpdates(t, 1)[0]
+
+			taskClient := &client.TaskResourceApiService{APIClient: f.apiClient()}
+			if _, err := completeTask(context.Background(), taskClient, "t1", "wf1", tt.output()); err != nil {
+				t.Fatalf("completeTask: %v", err)
+			}
+			viaHelper := f.waitUpdates(t, 2)[1]
+
+			if viaHelper.TaskId != "t1" || viaHelper.WorkflowInstanceId != "wf1" {
+				t.Errorf("completeTask sent task %s of workflow %s, want t1 of wf1", viaHelper.TaskId, viaHelper.WorkflowInstanceId)
+			}
+			if viaHelper.Status != viaRunner.Status || viaHelper.ReasonForIncompletion != viaRunner.ReasonForIncompletion {
+				t.Errorf("completeTask sent %s (%q), runner %s (%q)", viaHelper.Status, viaHelper.ReasonForIncompletion, viaRunner.Status, viaRunner.ReasonForIncompletion)
+			}
+			if !reflect.DeepEqual(viaHelper.OutputData, viaRunner.OutputData) {
+				t.Errorf("completeTask sent output %v, runner %v", viaHelper.OutputData, viaRunner.OutputData)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"time"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// completionStashTTL bounds how long a result waits for the SDK to report its
+// update, covering the SDK's update retries. Results whose update failed are
+// dropped after it.
+const completionStashTTL = 2 * time.Minute
+
+// updatedLogMessage is logged by the TaskRunner once a task result has been
+// accepted by Conductor.
+const updatedLogMessage = "Updated task of type"
+
+// pendingCompletion is a task result awaiting its update.
+type pendingCompletion struct {
+	taskName string
+	result   *model.TaskResult
+}
+
+// SetCompletionHandler makes the supervisor call fn with the task name and
+// result of every task result Conductor accepted, except results leaving the
+// task IN_PROGRESS. fn runs on the SDK's worker goroutine, so it must not
+// block.
+func (s *supervisor) SetCompletionHandler(fn func(taskName string, result *model.TaskResult)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onCompletion = fn
+}
+
+// stashCompletion keeps the result the SDK will send for t until the SDK
+// reports the update. It mirrors how the runner turns a handler outcome into
+// a task result.
+func (s *supervisor) stashCompletion(t *model.Task, res interface{}, err error) {
+	s.mu.Lock()
+	enabled := s.onCompletion != nil
+	s.mu.Unlock()
+	if !enabled {
+		return
+	}
+	result := resultOf(t, res, err)
+	if result.Status == model.InProgressTask {
+		return
+	}
+	pending := &pendingCompletion{taskName: t.TaskDefName, result: result}
+	s.completions.Store(t.TaskId, pending)
+	time.AfterFunc(completionStashTTL, func() { s.completions.CompareAndDelete(t.TaskId, pending) })
+}
+
+// taskUpdated hands the stashed result of taskID to the completion handler.
+func (s *supervisor) taskUpdated(taskID string) {
+	v, ok := s.completions.LoadAndDelete(taskID)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	fn := s.onCompletion
+	s.mu.Unlock()
+	if fn != nil {
+		pending := v.(*pendingCompletion)
+		fn(pending.taskName, pending.result)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// Compressed outputs are replaced by an envelope of the form
+//
+//	{"_compression": "gzip", "_payload": "<base64 of the gzipped JSON output>"}
+//
+// Consumers must map the whole task output (e.g. "${ref.output}") rather than
+// individual keys, and decompress it with decompressPayload before use.
+// withInputDecompression does this for every input value carrying the envelope.
+const (
+	compressionKey = "_compression"
+	payloadKey     = "_payload"
+	gzipEncoding   = "gzip"
+)
+
+// withOutputCompression wraps a worker handler to gzip its output when the
+// JSON encoding is larger than threshold bytes. Smaller outputs are sent as
+// is, and so are those of the tasks not in taskNames unless it is empty.
+func withOutputCompression(threshold int, taskNames map[string]bool, fn func(*model.Task) (interface{}, error)) func(*model.Task) (interface{}, error) {
+	return func(t *model.Task) (interface{}, error) {
+		res, err := fn(t)
+		if err != nil || res == nil || len(taskNames) > 0 && !taskNames[t.TaskDefName] {
+			return res, err
+		}
+		raw, mErr := json.Marshal(res)
+		if mErr != nil || len(raw) <= threshold {
+			return res, err
+		}
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, wErr := zw.Write(raw); wErr != nil {
+			return nil, fmt.Errorf("failed to compress output: %w", wErr)
+		}
+		if cErr := zw.Close(); cErr != nil {
+			return nil, fmt.Errorf("failed to compress output: %w", cErr)
+		}
+		return map[string]interface{}{
+			compressionKey: gzipEncoding,
+			payloadKey:     base64.StdEncoding.EncodeToString(buf.Bytes()),
+		}, nil
+	}
+}
+
+// withInputDecompression wraps a worker handler so that input values carrying a
+// compression envelope are replaced by their decompressed content.
+func withInputDecompression(fn func(*model.Task) (interface{}, error)) func(*model.Task) (interface{}, error) {
+	return func(t *model.Task) (interface{}, error) {
+		for k, v := range t.InputData {
+			m, ok := v.(map[string]interface{})
+			if !ok || m[compressionKey] == nil {
+				continue
+			}
+			out, err := decompressPayload(m)
+			if err != nil {
+				return nil, model.NewNonRetryableError(fmt.Errorf("input %q: %w", k, err))
+			}
+			t.InputData[k] = out
+		}
+		return fn(t)
+	}
+}
+
+// decompressPayload decodes an envelope produced by withOutputCompression.
+func decompressPayload(envelope map[string]interface{}) (map[string]interface{}, error) {
+	if enc, _ := envelope[compressionKey].(string); enc != gzipEncoding {
+		return nil, fmt.Errorf("unsupported compression %v", envelope[compressionKey])
+	}
+	payload, _ := envelope[payloadKey].(string)
+	raw, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid compressed payload: %w", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid compressed payload: %w", err)
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid compressed payload: %w", err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("invalid compressed payload: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/conductor-sdk/conductor-go/sdk/client"
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+	"github.com/conductor-sdk/conductor-go/sdk/settings"
+	"github.com/conductor-sdk/conductor-go/sdk/worker"
+)
+
+// fakeConductor is a Conductor server answering batch polls with the tasks
+// queued for them and recording every task result it is sent.
+type fakeConductor struct {
+	*httptest.Server
+	mu      sync.Mutex
+	queued  map[string][]model.Task
+	updates []model.TaskResult
+}
+
+func newFakeConductor(t *testing.T) *fakeConductor {
+	t.Helper()
+	f := &fakeConductor{queued: map[string][]model.Task{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeConductor) serve(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/api/tasks/poll/batch/"):
+		taskName := strings.TrimPrefix(r.URL.Path, "/api/tasks/poll/batch/")
+		f.mu.Lock()
+		tasks := f.queued[taskName]
+		delete(f.queued, taskName)
+		f.mu.Unlock()
+		if len(tasks) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tasks)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/tasks":
+		var result model.TaskResult
+		if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.updates = append(f.updates, result)
+		f.mu.Unlock()
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(result.TaskId))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// apiClient returns a client of the server.
+func (f *fakeConductor) apiClient() *client.APIClient {
+	return client.NewAPIClient(nil, settings.NewHttpSettings(f.URL+"/api"))
+}
+
+// enqueue queues tasks for the next poll of taskName.
+func (f *fakeConductor) enqueue(taskName string, tasks ...model.Task) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queued[taskName] = append(f.queued[taskName], tasks...)
+}
+
+// waitUpdates waits up to a few seconds for the server to have received n
+// task results and returns them.
+func (f *fakeConductor) waitUpdates(t *testing.T, n int) []model.TaskResult {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		f.mu.Lock()
+		updates := append([]model.TaskResult(nil), f.updates...)
+		f.mu.Unlock()
+		if len(updates) >= n {
+			return updates
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d task update(s), want %d", len(updates), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// newTestSupervisor returns a supervisor whose runner talks to f. Its workers
+// are shut down when the test ends.
+func newTestSupervisor(t *testing.T, f *fakeConductor) *supervisor {
+	t.Helper()
+	sup := newSupervisor(worker.NewTaskRunnerWithApiClient(f.apiClient()))
+	t.Cleanup(func() {
+		sup.mu.Lock()
+		taskNames := make([]string, 0, len(sup.workers))
+		for taskName := range sup.workers {
+			taskNames = append(taskNames, taskName)
+		}
+		sup.mu.Unlock()
+		for _, taskName := range taskNames {
+			sup.Shutdown(taskName)
+		}
+	})
+	return sup
+}
+
+// testWorker returns a worker polling taskName every 10ms.
+func testWorker(taskName string, fn model.ExecuteTaskFunction) worker.Worker {
+	return worker.NewWorker(taskName, fn, worker.WithBatchSize(1), worker.WithPollInterval(10*time.Millisecond))
+}
+
+func TestFakeConductorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    model.ExecuteTaskFunction
+		wantStatus model.TaskResultStatus
+		wantOutput map[string]interface{}
+	}{
+		{
+			name:       "completed",
+			handler:    func(*model.Task) (interface{}, error) { return map[string]interface{}{"ok": true}, nil },
+			wantStatus: model.CompletedTask,
+			wantOutput: map[string]interface{}{"ok": true},
+		},
+		{
+			name:       "terminal failure",
+			handler:    func(*model.Task) (interface{}, error) { return nil, model.NewNonRetryableError(errTest) },
+			wantStatus: model.FailedWithTerminalErrorTask,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeConductor(t)
+			sup := newTestSupervisor(t, f)
+			f.enqueue("fake_task", model.Task{TaskId: "t1", WorkflowInstanceId: "wf1", TaskDefName: "fake_task", TaskType: "fake_task"})
+			if err := sup.RegisterWorker(testWorker("fake_task", tt.handler)); err != nil {
+				t.Fatalf("RegisterWorker: %v", err)
+			}
+			got := f.waitUpdates(t, 1)[0]
+			if got.TaskId != "t1" || got.WorkflowInstanceId != "wf1" {
+				t.Errorf("update for task %s of workflow %s, want t1 of wf1", got.TaskId, got.WorkflowInstanceId)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", got.Status, tt.wantStatus)
+			}
+			if tt.wantOutput != nil && got.OutputData["ok"] != tt.wantOutput["ok"] {
+				t.Errorf("output = %v, want %v", got.OutputData, tt.wantOutput)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"time"
+)
+
+// taskConfig is the runtime polling configuration of one task. Zero or
+// missing fields leave the current value unchanged.
+type taskConfig struct {
+	BatchSize      int  `json:"batch_size,omitempty"`
+	PollIntervalMs int  `json:"poll_interval_ms,omitempty"`
+	PollTimeoutMs  *int `json:"poll_timeout_ms,omitempty"`
+	// MaxInFlight caps the tasks in flight at once; nil leaves it unchanged
+	// and 0 removes the cap.
+	MaxInFlight *int `json:"max_in_flight,omitempty"`
+}
+
+// ReconfigureTask applies cfg to a registered task. Active batch size boosts
+// are kept on top of the new batch size, and backpressure keeps throttling the
+// new values.
+func (s *supervisor) ReconfigureTask(taskName string, cfg taskConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.workers[taskName]; !ok {
+		return fmt.Errorf("no worker registered for task %s", taskName)
+	}
+	if cfg.BatchSize > 0 {
+		if err := s.setBatchSizeLocked(taskName, cfg.BatchSize+s.boostDelta[taskName]); err != nil {
+			return err
+		}
+	}
+	if cfg.PollIntervalMs > 0 {
+		s.pollIntervals[taskName] = time.Duration(cfg.PollIntervalMs) * time.Millisecond
+		if err := s.applyPollIntervalLocked(taskName); err != nil {
+			return err
+		}
+	}
+	if cfg.PollTimeoutMs != nil {
+		if err := s.runner.SetPollTimeoutForTask(taskName, time.Duration(*cfg.PollTimeoutMs)*time.Millisecond); err != nil {
+			return err
+		}
+	}
+	if cfg.MaxInFlight != nil {
+		s.setMaxInFlightLocked(taskName, *cfg.MaxInFlight)
+	}
+	s.checkPollTiming(taskName)
+	return nil
+}
+
+// checkPollTiming logs a one-time warning when the poll interval of taskName
+// is longer than its poll timeout. The runner sleeps for the poll interval
+// after every empty poll, on top of the long poll itself, so an interval
+// longer than the timeout makes the interval, not the long poll, set the pace.
+// With long polling keep the interval at or below the timeout, ideally small.
+// The caller must hold s.mu.
+func (s *supervisor) checkPollTiming(taskName string) {
+	if s.pollTimingWarned[taskName] {
+		return
+	}
+	interval := s.pollIntervals[taskName]
+	timeout, err := s.runner.GetPollTimeoutForTask(taskName)
+	if err != nil || timeout <= 0 || interval <= timeout {
+		return
+	}
+	s.pollTimingWarned[taskName] = true
+	log.Printf("Supervisor: WARNING poll interval %s of %s is longer than its poll timeout %s; empty polls will wait %s in total, consider a poll interval of at most %s", interval, taskName, timeout, interval+timeout, timeout)
+}
+
+// ScaleBatchSizes multiplies the batch size of every registered task by factor,
+// rounding to the nearest integer and keeping at least 1 for tasks whose batch
+// size was not zero. All tasks are scaled under one lock, so concurrent
+// reconfigurations see either none or all of the new sizes, and a task failing
+// to take its new size restores the previous sizes of the tasks scaled before
+// it. It returns the resulting batch size per task.
+func (s *supervisor) ScaleBatchSizes(factor float64) (map[string]int, error) {
+	if factor <= 0 || math.IsInf(factor, 0) || math.IsNaN(factor) {
+		return nil, fmt.Errorf("invalid batch size scale factor %v", factor)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sizes := make(map[string]int, len(s.workers))
+	previous := make(map[string]int, len(s.workers))
+	for taskName := range s.workers {
+		cur := s.batchSizeLocked(taskName)
+		size := int(math.Round(float64(cur) * factor))
+		if cur > 0 && size < 1 {
+			size = 1
+		}
+		if err := s.setBatchSizeLocked(taskName, size); err != nil {
+			for scaled, size := range previous {
+				if rbErr := s.setBatchSizeLocked(scaled, size); rbErr != nil {
+					log.Printf("Supervisor: failed to restore batch size %d of %s: %v", size, scaled, rbErr)
+				}
+			}
+			return nil, fmt.Errorf("scale batch size for %s: %w", taskName, err)
+		}
+		previous[taskName] = cur
+		sizes[taskName] = size
+	}
+	log.Printf("Supervisor: scaled batch sizes by %v: %v", factor, sizes)
+	return sizes, nil
+}
+
+// TaskRuntimeConfig is the runtime configuration and state of one task.
+// BatchSize and PollIntervalMs are the configured values; while backpressure
+// throttles the task, the runner polls with the effective ones.
+type TaskRuntimeConfig struct {
+	Domain         string `json:"domain,omitempty"`
+	BatchSize      int    `json:"batch_size"`
+	BoostDelta     int    `json:"boost_delta"`
+	PollIntervalMs int64  `json:"poll_interval_ms"`
+	// EffectiveBatchSize and EffectivePollIntervalMs are set while they
+	// differ from the configured values.
+	EffectiveBatchSize      int   `json:"effective_batch_size,omitempty"`
+	EffectivePollIntervalMs int64 `json:"effective_poll_interval_ms,omitempty"`
+	// PollTimeoutMs is negative when the server default is used.
+	PollTimeoutMs int64    `json:"poll_timeout_ms"`
+	Paused        bool     `json:"paused"`
+	PauseReasons  []string `json:"pause_reasons,omitempty"`
+	Running       int      `json:"running"`
+	MaxInFlight   int      `json:"max_in_flight,omitempty"`
+	// BackpressureLevel is how far a backpressure gate throttles the task.
+	BackpressureLevel int `json:"backpressure_level,omitempty"`
+}
+
+// RunnerConfig is a snapshot of the configuration of every registered task.
+type RunnerConfig struct {
+	Tasks map[string]TaskRuntimeConfig `json:"tasks"`
+}
+
+// DumpConfig returns a snapshot of the configuration of every registered task.
+// It is taken under the supervisor lock, which every reconfiguration holds, so
+// it is never torn across tasks. The snapshot shares no state with the
+// supervisor.
+func (s *supervisor) DumpConfig() RunnerConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cfg := RunnerConfig{Tasks: make(map[string]TaskRuntimeConfig, len(s.workers))}
+	for taskName := range s.workers {
+		interval := s.pollIntervals[taskName]
+		timeout, _ := s.runner.GetPollTimeoutForTask(taskName)
+		tc := TaskRuntimeConfig{
+			Domain:            s.workers[taskName].Options().Domain,
+			BatchSize:         s.batchSizeLocked(taskName),
+			BoostDelta:        s.boostDelta[taskName],
+			PollIntervalMs:    interval.Milliseconds(),
+			PollTimeoutMs:     timeout.Milliseconds(),
+			Paused:            len(s.pauseReasons[taskName]) > 0,
+			PauseReasons:      s.pauseReasonsOf(taskName),
+			Running:           s.inFlight[taskName],
+			MaxInFlight:       s.maxInFlight[taskName],
+			BackpressureLevel: s.backpressure[taskName],
+		}
+		if size := s.runner.GetBatchSizeForTask(taskName); size != tc.BatchSize {
+			tc.EffectiveBatchSize = size
+		}
+		if effective, _ := s.runner.GetPollIntervalForTask(taskName); effective != interval {
+			tc.EffectivePollIntervalMs = effective.Milliseconds()
+		}
+		cfg.Tasks[taskName] = tc
+	}
+	return cfg
+}
+
+// ReloadConfig reads a JSON file mapping task names to taskConfig, e.g.
+//
+//	{"create_user_task": {"batch_size": 5, "poll_interval_ms": 200}}
+//
+// and applies each entry. Entries for tasks that aren't registered are logged
+// and skipped rather than failing the reload.
+func (s *supervisor) ReloadConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read worker config: %w", err)
+	}
+	var cfgs map[string]taskConfig
+	if err := json.Unmarshal(data, &cfgs); err != nil {
+		return fmt.Errorf("failed to parse worker config %s: %w", path, err)
+	}
+	for taskName, cfg := range cfgs {
+		if !s.isRegistered(taskName) {
+			log.Printf("Supervisor: ignoring config for unknown task %s", taskName)
+			continue
+		}
+		if err := s.ReconfigureTask(taskName, cfg); err != nil {
+			return fmt.Errorf("failed to reconfigure %s: %w", taskName, err)
+		}
+		log.Printf("Supervisor: reconfigured %s: %+v", taskName, cfg)
+	}
+	return nil
+}
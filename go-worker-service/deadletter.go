@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/antihax/optional"
+	"github.com/conductor-sdk/conductor-go/sdk/client"
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// deadLetterListLimit is the default number of entries GET /dead-letter lists.
+const deadLetterListLimit = 100
+
+// DeadLetter is a task that failed for good, kept in worker_dead_letter for
+// manual review.
+type DeadLetter struct {
+	TaskID     string          `json:"task_id"`
+	WorkflowID string          `json:"workflow_id"`
+	TaskType   string          `json:"task_type"`
+	Input      json.RawMessage `json:"input"`
+	Error      string          `json:"error"`
+	RetryCount int32           `json:"retry_count"`
+	CreatedAt  time.Time       `json:"created_at"`
+	ReplayedAt *time.Time      `json:"replayed_at,omitempty"`
+}
+
+// permanentFailure reports whether Conductor will not retry the task t given
+// the result res and error err its handler chain returned after status
+// mapping, and why it failed. Failures are permanent when terminal, or once
+// the task has used up the retryCount of its task definition.
+func permanentFailure(t *model.Task, res interface{}, err error) (string, bool) {
+	retriesLeft := true
+	if limit, ok := retryLimit(t.TaskDefName); ok {
+		retriesLeft = t.RetryCount < limit
+	}
+	if err != nil {
+		var terminal *model.NonRetryableError
+		return err.Error(), errors.As(err, &terminal) || !retriesLeft
+	}
+	if r, ok := res.(*model.TaskResult); ok {
+		switch r.Status {
+		case model.FailedWithTerminalErrorTask:
+			return r.ReasonForIncompletion, true
+		case model.FailedTask:
+			return r.ReasonForIncompletion, !retriesLeft
+		}
+	}
+	return "", false
+}
+
+// withDeadLetter wraps a worker handler to record the tasks that fail for good
+// in worker_dead_letter. It must wrap the status mapping, which decides
+// whether a failure is terminal.
+func withDeadLetter(fn func(*model.Task) (interface{}, error)) func(*model.Task) (interface{}, error) {
+	return func(t *model.Task) (interface{}, error) {
+		res, err := fn(t)
+		if reason, ok := permanentFailure(t, res, err); ok {
+			recordDeadLetter(t, reason)
+		}
+		return res, err
+	}
+}
+
+// recordDeadLetter writes t to worker_dead_letter, its input redacted as by
+// redactOutput. Recording the same task again only updates its error.
+func recordDeadLetter(t *model.Task, errText string) {
+	if db == nil {
+		return
+	}
+	inBytes, _ := json.Marshal(redactOutput(t.InputData))
+	_, err := taskDB(t).ExecContext(context.Background(), `
+		INSERT INTO worker_dead_letter (task_id, workflow_id, task_type, input, error, retry_count)
+		VALUES ($1, $2, $3, $4::jsonb, $5, $6)
+		ON CONFLICT (task_id) DO UPDATE SET error = EXCLUDED.error
+	`, t.TaskId, t.WorkflowInstanceId, t.TaskType, string(inBytes), errText, t.RetryCount)
+	if err != nil {
+		log.Printf("failed to record dead letter for task %s: %v", t.TaskId, err)
+		return
+	}
+	log.Printf("Task %s of workflow %s failed for good, recorded as dead letter", t.TaskId, t.WorkflowInstanceId)
+}
+
+// deadLetterListHandler lists the most recent dead letters, newest first, up
+// to ?limit= entries.
+func deadLetterListHandler(w http.ResponseWriter, r *http.Request) {
+	limit := deadLetterListLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	if db == nil {
+		http.Error(w, "Database unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	rows, err := db.QueryContext(r.Context(), `
+		SELECT task_id, workflow_id, task_type, input, error, retry_count, created_at, replayed_at
+		FROM worker_dead_letter ORDER BY created_at DESC LIMIT $1`, limit)
+	if err != nil {
+		log.Printf("Admin: failed to list dead letters: %v", err)
+		http.Error(w, "Failed to list dead letters", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	letters := []DeadLetter{}
+	for rows.Next() {
+		var d DeadLetter
+		var input []byte
+		if err := rows.Scan(&d.TaskID, &d.WorkflowID, &d.TaskType, &input, &d.Error, &d.RetryCount, &d.CreatedAt, &d.ReplayedAt); err != nil {
+			log.Printf("Admin: failed to read dead letter: %v", err)
+			http.Error(w, "Failed to list dead letters", http.StatusInternalServerError)
+			return
+		}
+		d.Input = input
+		letters = append(letters, d)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Admin: failed to list dead letters: %v", err)
+		http.Error(w, "Failed to list dead letters", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(letters)
+}
+
+// deadLetterReplayHandler re-runs the dead-lettered task in the path by
+// retrying its workflow from the failed task in Conductor, which schedules
+// the task again. The entry is kept, marked with the replay time.
+func deadLetterReplayHandler(workflowClient *client.WorkflowResourceApiService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		taskID := r.PathValue("task_id")
+		if db == nil {
+			http.Error(w, "Database unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var workflowID string
+		err := db.QueryRowContext(r.Context(), `SELECT workflow_id FROM worker_dead_letter WHERE task_id = $1`, taskID).Scan(&workflowID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Unknown dead letter", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Admin: failed to load dead letter %s: %v", taskID, err)
+			http.Error(w, "Failed to load dead letter", http.StatusInternalServerError)
+			return
+		}
+
+		opts := &client.WorkflowResourceApiRetryOpts{ResumeSubworkflowTasks: optional.NewBool(true)}
+		if _, err := workflowClient.Retry(r.Context(), workflowID, opts); err != nil {
+			log.Printf("Admin: failed to retry workflow %s for dead letter %s: %v", workflowID, taskID, err)
+			http.Error(w, "Failed to retry workflow: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		if _, err := db.ExecContext(r.Context(), `UPDATE worker_dead_letter SET replayed_at = NOW() WHERE task_id = $1`, taskID); err != nil {
+			log.Printf("Admin: failed to mark dead letter %s replayed: %v", taskID, err)
+		}
+		log.Printf("Admin: replayed dead letter %s by retrying workflow %s", taskID, workflowID)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{
+			"task_id":     taskID,
+			"workflow_id": workflowID,
+			"status":      "Workflow retried",
+		})
+	}
+}
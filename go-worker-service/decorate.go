@@ -0,0 +1,65 @@
+package main
+
+import (
+	"runtime/debug"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// Build metadata, set with -ldflags "-X main.version=... -X main.gitSHA=...".
+var (
+	version = "dev"
+	gitSHA  = ""
+)
+
+// workerMetadataKey is the output key holding the metadata added by
+// workerMetadataDecorator.
+const workerMetadataKey = "_worker"
+
+// withResultDecorator wraps a worker handler so that decorate is applied to
+// the task result right before the runner sends it to Conductor. It must wrap
+// every other middleware so that later output handling, such as compression,
+// cannot strip the decorations.
+func withResultDecorator(decorate func(*model.TaskResult), fn func(*model.Task) (interface{}, error)) func(*model.Task) (interface{}, error) {
+	return func(t *model.Task) (interface{}, error) {
+		res, err := fn(t)
+		var result *model.TaskResult
+		if err != nil && res == nil {
+			result = model.NewTaskResultFromTaskWithError(t, err)
+		} else {
+			var cErr error
+			if result, cErr = model.GetTaskResultFromTaskExecutionOutput(t, res); cErr != nil {
+				return res, err
+			}
+		}
+		decorate(result)
+		return result, err
+	}
+}
+
+// workerMetadataDecorator returns a decorator adding the worker version, git
+// SHA and hostname to every task output under workerMetadataKey. The hostname
+// is the worker id workerID, which is generated when the host has no name.
+func workerMetadataDecorator(workerID string) func(*model.TaskResult) {
+	sha := gitSHA
+	if sha == "" {
+		if info, ok := debug.ReadBuildInfo(); ok {
+			for _, s := range info.Settings {
+				if s.Key == "vcs.revision" {
+					sha = s.Value
+				}
+			}
+		}
+	}
+	metadata := map[string]interface{}{
+		"version":  version,
+		"git_sha":  sha,
+		"hostname": workerID,
+	}
+	return func(result *model.TaskResult) {
+		if result.OutputData == nil {
+			result.OutputData = make(map[string]interface{})
+		}
+		result.OutputData[workerMetadataKey] = metadata
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// taskDedupe remembers the tasks this process executed recently, by task id,
+// with their outcome. Entries expire ttl after the task started, and only the
+// max most recent ones are kept.
+type taskDedupe struct {
+	ttl time.Duration
+	max int
+
+	mu      sync.Mutex
+	entries map[string]*dedupeEntry
+	// order holds the task ids of entries oldest first.
+	order []string
+}
+
+// dedupeEntry is the outcome of a task execution, available once done is
+// closed.
+type dedupeEntry struct {
+	started time.Time
+	done    chan struct{}
+	res     interface{}
+	err     error
+}
+
+func newTaskDedupe(ttl time.Duration, max int) *taskDedupe {
+	if max < 1 {
+		max = 1
+	}
+	return &taskDedupe{ttl: ttl, max: max, entries: make(map[string]*dedupeEntry)}
+}
+
+// claim returns the entry of a recent execution of taskID, or claims taskID
+// with a new entry, reporting true, if there is none.
+func (d *taskDedupe) claim(taskID string) (*dedupeEntry, bool) {
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for len(d.order) > 0 {
+		oldest := d.entries[d.order[0]]
+		if len(d.order) < d.max && now.Sub(oldest.started) < d.ttl {
+			break
+		}
+		delete(d.entries, d.order[0])
+		d.order = d.order[1:]
+	}
+	if e, ok := d.entries[taskID]; ok {
+		return e, false
+	}
+	e := &dedupeEntry{started: now, done: make(chan struct{})}
+	d.entries[taskID] = e
+	d.order = append(d.order, taskID)
+	return e, true
+}
+
+// withDedupe wraps a worker handler so that a task delivered again to this
+// process within the dedupe window isn't executed twice. The duplicate is
+// logged and gets the outcome of the first delivery, waiting for it if still
+// running, so a result Conductor never received is sent again. Deliveries to
+// other processes aren't deduplicated.
+func withDedupe(d *taskDedupe, fn func(*model.Task) (interface{}, error)) func(*model.Task) (interface{}, error) {
+	return func(t *model.Task) (interface{}, error) {
+		e, first := d.claim(t.TaskId)
+		if !first {
+			log.Printf("Task %s of workflow %s delivered again, reusing the outcome of its first delivery", t.TaskId, t.WorkflowInstanceId)
+			<-e.done
+			return e.res, e.err
+		}
+		finished := false
+		defer func() {
+			if !finished {
+				e.err = fmt.Errorf("first delivery of task %s panicked", t.TaskId)
+			}
+			close(e.done)
+		}()
+		e.res, e.err = fn(t)
+		finished = true
+		return e.res, e.err
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+func init() {
+	// Workflows passing the account as an object can point the dispatch
+	// into it, e.g. ACCOUNT_TYPE_INPUT=account.type
+	handlers.Register("create_account_task", DispatchWorker(getEnv("ACCOUNT_TYPE_INPUT", "account_type"), map[string]model.ExecuteTaskFunction{
+		"enterprise": createEnterpriseWorker,
+		"user":       onboardEmployeeWorker,
+	}))
+}
+
+// DispatchWorker returns a handler that routes each task to the handler of
+// handlers registered for the string value of its key input, so one Conductor
+// task definition can be served by several Go handlers. key may be a dotted
+// path into nested inputs, as read by inputPath, e.g. "account.type". A
+// missing, non-string or unknown value fails the task for good, as retrying
+// it would route it the same way.
+func DispatchWorker(key string, handlers map[string]model.ExecuteTaskFunction) model.ExecuteTaskFunction {
+	return func(t *model.Task) (interface{}, error) {
+		raw, _ := inputPath(t, key)
+		v, ok := raw.(string)
+		if !ok {
+			return nil, model.NewNonRetryableError(fmt.Errorf("missing %s in task input", key))
+		}
+		fn, ok := handlers[v]
+		if !ok {
+			return nil, model.NewNonRetryableError(fmt.Errorf("no handler for %s %q", key, v))
+		}
+		return fn(t)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+func TestDispatchWorker(t *testing.T) {
+	route := func(name string) model.ExecuteTaskFunction {
+		return func(*model.Task) (interface{}, error) { return map[string]interface{}{"handler": name}, nil }
+	}
+	dispatch := DispatchWorker("account_type", map[string]model.ExecuteTaskFunction{
+		"enterprise": route("enterprise"),
+		"user":       route("user"),
+	})
+	tests := []struct {
+		name        string
+		input       map[string]interface{}
+		wantHandler string
+		wantErr     string
+	}{
+		{name: "enterprise", input: map[string]interface{}{"account_type": "enterprise"}, wantHandler: "enterprise"},
+		{name: "user", input: map[string]interface{}{"account_type": "user"}, wantHandler: "user"},
+		{name: "unknown value", input: map[string]interface{}{"account_type": "partner"}, wantErr: `no handler for account_type "partner"`},
+		{name: "missing key", input: map[string]interface{}{"type": "user"}, wantErr: "missing account_type in task input"},
+		{name: "non-string value", input: map[string]interface{}{"account_type": 1.0}, wantErr: "missing account_type in task input"},
+		{name: "nil input", wantErr: "missing account_type in task input"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := dispatch(&model.Task{TaskDefName: "create_account_task", InputData: tt.input})
+			if tt.wantErr != "" {
+				if _, terminal := err.(*model.NonRetryableError); !terminal || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want a *model.NonRetryableError containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if got := out.(map[string]interface{})["handler"]; got != tt.wantHandler {
+				t.Errorf("routed to %v, want %s", got, tt.wantHandler)
+			}
+		})
+	}
+}
+
+func TestDispatchWorkerDottedKey(t *testing.T) {
+	dispatch := DispatchWorker("account.type", map[string]model.ExecuteTaskFunction{
+		"user": func(*model.Task) (interface{}, error) { return map[string]interface{}{"handler": "user"}, nil },
+	})
+	tests := []struct {
+		name    string
+		input   map[string]interface{}
+		wantErr string
+	}{
+		{name: "nested value", input: map[string]interface{}{"account": map[string]interface{}{"type": "user"}}},
+		{name: "unknown nested value", input: map[string]interface{}{"account": map[string]interface{}{"type": "partner"}}, wantErr: `no handler for account.type "partner"`},
+		{name: "missing nested key", input: map[string]interface{}{"account": map[string]interface{}{}}, wantErr: "missing account.type in task input"},
+		{name: "missing parent", input: map[string]interface{}{"type": "user"}, wantErr: "missing account.type in task input"},
+		{name: "flat key with the dotted name", input: map[string]interface{}{"account.type": "user"}, wantErr: "missing account.type in task input"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := dispatch(&model.Task{TaskDefName: "create_account_task", InputData: tt.input})
+			if tt.wantErr == "" {
+				if err != nil || out.(map[string]interface{})["handler"] != "user" {
+					t.Errorf("dispatch = %v, %v, want the user handler", out, err)
+				}
+				return
+			}
+			if _, terminal := err.(*model.NonRetryableError); !terminal || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want a *model.NonRetryableError containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateAccountTaskRegistered(t *testing.T) {
+	fn, ok := handlers.Handlers(nil)["create_account_task"]
+	if !ok {
+		t.Fatal("create_account_task not registered")
+	}
+	tests := []struct {
+		name    string
+		input   map[string]interface{}
+		wantErr string
+	}{
+		{name: "missing account type", input: map[string]interface{}{}, wantErr: "missing account_type"},
+		{name: "unknown account type", input: map[string]interface{}{"account_type": "partner"}, wantErr: `no handler for account_type "partner"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := fn(&model.Task{TaskDefName: "create_account_task", InputData: tt.input})
+			if _, terminal := err.(*model.NonRetryableError); !terminal || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want a *model.NonRetryableError containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseTaskDomains parses TASK_DOMAINS, a comma separated list of
+// task=domain pairs, into the domain of each task. Every task must be one of
+// known and appear once. An empty value maps no task, leaving every worker
+// in the default domain.
+func parseTaskDomains(raw string, known []string) (map[string]string, error) {
+	domains := make(map[string]string)
+	if strings.TrimSpace(raw) == "" {
+		return domains, nil
+	}
+	isKnown := make(map[string]bool, len(known))
+	for _, taskName := range known {
+		isKnown[taskName] = true
+	}
+	for _, entry := range strings.Split(raw, ",") {
+		taskName, domain, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		taskName, domain = strings.TrimSpace(taskName), strings.TrimSpace(domain)
+		if !ok || taskName == "" || domain == "" {
+			return nil, fmt.Errorf("invalid entry %q: want task=domain", entry)
+		}
+		if !isKnown[taskName] {
+			return nil, fmt.Errorf("invalid entry %q: unknown task %s", entry, taskName)
+		}
+		if _, dup := domains[taskName]; dup {
+			return nil, fmt.Errorf("invalid entry %q: task %s is mapped twice", entry, taskName)
+		}
+		domains[taskName] = domain
+	}
+	return domains, nil
+}
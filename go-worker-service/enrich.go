@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// profileClient fetches user profiles from the external profile service.
+var profileClient = &http.Client{Timeout: 10 * time.Second}
+
+// flagOptionalProfile completes enrich_user_task unenriched for users the
+// profile service doesn't know, instead of failing it for good.
+const flagOptionalProfile = "optional-profile"
+
+// httpStatusError is an unexpected HTTP response from an external service.
+// Server errors and throttling are retryable; other client errors are not.
+type httpStatusError struct {
+	Service    string
+	StatusCode int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("%s responded with HTTP %d", e.Service, e.StatusCode)
+}
+
+// Retryable implements retryable.
+func (e *httpStatusError) Retryable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+func init() {
+	handlers.Register("enrich_user_task", enrichUserWorker)
+	handlers.After("enrich_user_task", "create_user_task")
+}
+
+// enrichUserWorker implements the 'enrich_user_task': it fetches the profile of
+// user_id from PROFILE_SERVICE_URL (GET <url>/<user_id>, answering a JSON
+// object) and stores it in the details column of the user. Without
+// PROFILE_SERVICE_URL the task completes without enriching, and so does it for
+// unknown users with the optional-profile feature flag. With ARTIFACT_STORAGE
+// the raw profile is also attached as profile.json, its path output as
+// profile_path.
+func enrichUserWorker(t *model.Task) (interface{}, error) {
+	logger := taskLogger(t)
+	userIDFloat, ok := t.InputData["user_id"].(float64)
+	if !ok || userIDFloat <= 0 {
+		return nil, model.NewNonRetryableError(fmt.Errorf("missing or invalid user_id in task input"))
+	}
+	userID := int(userIDFloat)
+
+	baseURL := getEnv("PROFILE_SERVICE_URL", "")
+	if baseURL == "" {
+		logger.Printf("Worker 4: PROFILE_SERVICE_URL not set, skipping enrichment of user %d", userID)
+		return map[string]interface{}{"enriched": false}, nil
+	}
+
+	ctx := taskContext(t)
+	endpoint := strings.TrimRight(baseURL, "/") + "/" + url.PathEscape(fmt.Sprint(userID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, model.NewNonRetryableError(fmt.Errorf("invalid PROFILE_SERVICE_URL: %w", err))
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := profileClient.Do(req)
+	if err != nil {
+		logger.Printf("Worker 4 FAILED calling profile service: %v", err)
+		return nil, fmt.Errorf("failed to call profile service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound && taskFlag(t, flagOptionalProfile):
+		logger.Printf("Worker 4: No profile for user %d, skipping enrichment", userID)
+		return map[string]interface{}{"enriched": false}, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, model.NewNonRetryableError(fmt.Errorf("user %d not found in profile service", userID))
+	case resp.StatusCode != http.StatusOK:
+		logger.Printf("Worker 4 FAILED: profile service responded with HTTP %d", resp.StatusCode)
+		return nil, &httpStatusError{Service: "profile service", StatusCode: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile: %w", err)
+	}
+	var profile map[string]interface{}
+	if err := json.Unmarshal(body, &profile); err != nil {
+		return nil, model.NewNonRetryableError(fmt.Errorf("invalid profile for user %d: %w", userID, err))
+	}
+
+	res, err := taskDB(t).ExecContext(ctx, `UPDATE "user" SET details = $1::jsonb WHERE id = $2`, string(body), userID)
+	if err != nil {
+		logger.Printf("Worker 4 FAILED updating user: %v", err)
+		return nil, &dbError{Op: "store user profile", Err: err}
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, model.NewNonRetryableError(fmt.Errorf("user %d not found", userID))
+	}
+
+	out := map[string]interface{}{"enriched": true, "attributes": len(profile)}
+	// Keep the raw profile for downstream tasks without inlining it
+	if artifactUploader != nil {
+		p, err := attachArtifact(t, "profile.json", body)
+		if err != nil {
+			return nil, err
+		}
+		out["profile_path"] = p
+	}
+	logger.Printf("Worker 4: Enriched user %d with %d profile attribute(s)", userID, len(profile))
+	return out, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+	"github.com/lib/pq"
+)
+
+// retryable is implemented by handler errors that decide whether Conductor
+// should retry the task. Domain errors can embed a type implementing it.
+type retryable interface {
+	Retryable() bool
+}
+
+// statusMapper decides the final status of a task from its handler's output
+// and error, centralising the error policy of the worker.
+type statusMapper func(t *model.Task, out interface{}, err error) model.TaskResultStatus
+
+// statusMappers are the policies selectable with ERROR_POLICY.
+var statusMappers = map[string]statusMapper{
+	"default":   defaultStatusMapper,
+	"terminal":  terminalStatusMapper,
+	"retryable": retryableStatusMapper,
+}
+
+// defaultStatusMapper maps a handler outcome onto the Conductor task status:
+//
+//   - without an error the task is COMPLETED, or takes the status of a
+//     returned *model.TaskResult;
+//   - an error whose chain contains a *model.NonRetryableError always fails the
+//     task with FAILED_WITH_TERMINAL_ERROR, whatever Retryable reports;
+//   - otherwise the first error in the chain implementing retryable decides:
+//     Retryable() == false is terminal, true is a retryable FAILED;
+//   - any other error is a retryable FAILED.
+func defaultStatusMapper(t *model.Task, out interface{}, err error) model.TaskResultStatus {
+	if err == nil {
+		if r, ok := out.(*model.TaskResult); ok {
+			return r.Status
+		}
+		return model.CompletedTask
+	}
+	var terminal *model.NonRetryableError
+	if errors.As(err, &terminal) {
+		return model.FailedWithTerminalErrorTask
+	}
+	var r retryable
+	if errors.As(err, &r) && !r.Retryable() {
+		return model.FailedWithTerminalErrorTask
+	}
+	return model.FailedTask
+}
+
+// terminalStatusMapper fails every task returning an error for good.
+func terminalStatusMapper(t *model.Task, out interface{}, err error) model.TaskResultStatus {
+	if err != nil {
+		return model.FailedWithTerminalErrorTask
+	}
+	return defaultStatusMapper(t, out, nil)
+}
+
+// retryableStatusMapper leaves every failed task to Conductor's retries.
+func retryableStatusMapper(t *model.Task, out interface{}, err error) model.TaskResultStatus {
+	if err != nil {
+		return model.FailedTask
+	}
+	return defaultStatusMapper(t, out, nil)
+}
+
+// withStatusMapping wraps a worker handler to let mapper decide the status
+// Conductor receives for each task, overriding the SDK's own mapping.
+//
+// The SDK only recognises a *model.NonRetryableError returned as is, so
+// terminal errors are returned in that form and retryable ones never are;
+// any other status is returned as a *model.TaskResult.
+func withStatusMapping(mapper statusMapper, fn func(*model.Task) (interface{}, error)) func(*model.Task) (interface{}, error) {
+	return func(t *model.Task) (interface{}, error) {
+		res, err := fn(t)
+		return applyStatus(t, res, err, mapper(t, res, err))
+	}
+}
+
+func applyStatus(t *model.Task, res interface{}, err error, status model.TaskResultStatus) (interface{}, error) {
+	switch status {
+	case model.FailedWithTerminalErrorTask:
+		if err == nil {
+			err = fmt.Errorf("%s failed by the error policy", t.TaskType)
+		}
+		if _, ok := err.(*model.NonRetryableError); ok {
+			return res, err
+		}
+		return res, model.NewNonRetryableError(err)
+	case model.FailedTask:
+		if err == nil {
+			return res, fmt.Errorf("%s failed by the error policy", t.TaskType)
+		}
+		if _, ok := err.(*model.NonRetryableError); ok {
+			return res, errors.New(err.Error())
+		}
+		return res, err
+	}
+	r, isResult := res.(*model.TaskResult)
+	if err == nil {
+		if isResult && r.Status == status || !isResult && status == model.CompletedTask {
+			return res, nil
+		}
+	}
+	result := model.NewTaskResultFromTask(t)
+	if isResult {
+		*result = *r
+	} else if res != nil {
+		out, cErr := model.ConvertToMap(res)
+		if cErr != nil {
+			return nil, cErr
+		}
+		result.OutputData = out
+	}
+	if err != nil {
+		result.ReasonForIncompletion = err.Error()
+	}
+	result.Status = status
+	return result, nil
+}
+
+// dbError is a failed database operation. It keeps the driver error in its
+// chain and classifies it for retries: integrity constraint violations
+// (SQLSTATE class 23) and invalid data (class 22) will fail again with the
+// same input and are terminal; everything else, such as connection errors,
+// is retryable.
+type dbError struct {
+	Op  string
+	Err error
+}
+
+func (e *dbError) Error() string { return fmt.Sprintf("failed to %s: %v", e.Op, e.Err) }
+
+func (e *dbError) Unwrap() error { return e.Err }
+
+// Retryable implements retryable.
+func (e *dbError) Retryable() bool {
+	var pqErr *pq.Error
+	if errors.As(e.Err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "22", "23":
+			return false
+		}
+	}
+	return true
+}
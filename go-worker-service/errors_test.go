@@ -0,0 +1,89 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+	"github.com/lib/pq"
+)
+
+func TestStatusMappers(t *testing.T) {
+	terminal := model.NewNonRetryableError(errTest)
+	constraint := &dbError{Op: "insert", Err: &pq.Error{Code: "23505"}}
+	connection := &dbError{Op: "insert", Err: sql.ErrConnDone}
+	inProgress := &model.TaskResult{Status: model.InProgressTask}
+	tests := []struct {
+		name          string
+		out           interface{}
+		err           error
+		wantDefault   model.TaskResultStatus
+		wantTerminal  model.TaskResultStatus
+		wantRetryable model.TaskResultStatus
+	}{
+		{name: "success", out: map[string]interface{}{}, wantDefault: model.CompletedTask, wantTerminal: model.CompletedTask, wantRetryable: model.CompletedTask},
+		{name: "task result status", out: inProgress, wantDefault: model.InProgressTask, wantTerminal: model.InProgressTask, wantRetryable: model.InProgressTask},
+		{name: "plain error", err: errTest, wantDefault: model.FailedTask, wantTerminal: model.FailedWithTerminalErrorTask, wantRetryable: model.FailedTask},
+		{name: "non-retryable error", err: terminal, wantDefault: model.FailedWithTerminalErrorTask, wantTerminal: model.FailedWithTerminalErrorTask, wantRetryable: model.FailedTask},
+		{name: "wrapped non-retryable error", err: fmt.Errorf("wrapped: %w", terminal), wantDefault: model.FailedWithTerminalErrorTask, wantTerminal: model.FailedWithTerminalErrorTask, wantRetryable: model.FailedTask},
+		{name: "constraint violation", err: constraint, wantDefault: model.FailedWithTerminalErrorTask, wantTerminal: model.FailedWithTerminalErrorTask, wantRetryable: model.FailedTask},
+		{name: "connection error", err: connection, wantDefault: model.FailedTask, wantTerminal: model.FailedWithTerminalErrorTask, wantRetryable: model.FailedTask},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &model.Task{TaskType: "create_user_task"}
+			for policy, want := range map[string]model.TaskResultStatus{"default": tt.wantDefault, "terminal": tt.wantTerminal, "retryable": tt.wantRetryable} {
+				if got := statusMappers[policy](task, tt.out, tt.err); got != want {
+					t.Errorf("%s policy: status = %s, want %s", policy, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWithStatusMapping(t *testing.T) {
+	tests := []struct {
+		name         string
+		mapper       statusMapper
+		out          interface{}
+		err          error
+		wantTerminal bool
+		wantErr      bool
+		wantStatus   model.TaskResultStatus
+	}{
+		{name: "completed output passed through", mapper: defaultStatusMapper, out: map[string]interface{}{"id": 1}},
+		{name: "retryable error returned plain", mapper: defaultStatusMapper, err: errTest, wantErr: true},
+		{name: "terminal error returned as NonRetryableError", mapper: terminalStatusMapper, err: errTest, wantErr: true, wantTerminal: true},
+		{name: "non-retryable error made retryable", mapper: retryableStatusMapper, err: model.NewNonRetryableError(errTest), wantErr: true},
+		{
+			name:       "other status returned as task result",
+			mapper:     func(*model.Task, interface{}, error) model.TaskResultStatus { return model.InProgressTask },
+			out:        map[string]interface{}{"id": 1},
+			wantStatus: model.InProgressTask,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn := withStatusMapping(tt.mapper, func(*model.Task) (interface{}, error) { return tt.out, tt.err })
+			res, err := fn(&model.Task{TaskId: "t1", TaskType: "create_user_task"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want an error: %v", err, tt.wantErr)
+			}
+			// The SDK only recognises a NonRetryableError returned as is
+			if _, terminal := err.(*model.NonRetryableError); terminal != tt.wantTerminal {
+				t.Errorf("err = %#v, want a *model.NonRetryableError: %v", err, tt.wantTerminal)
+			}
+			if err != nil && !errors.Is(err, errTest) && err.Error() != errTest.Error() {
+				t.Errorf("err = %v, want the handler error", err)
+			}
+			if tt.wantStatus != "" {
+				r, ok := res.(*model.TaskResult)
+				if !ok || r.Status != tt.wantStatus || fmt.Sprint(r.OutputData["id"]) != "1" {
+					t.Errorf("res = %#v, want a %s result with the output", res, tt.wantStatus)
+				}
+			}
+		})
+	}
+}
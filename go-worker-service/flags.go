@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// FeatureFlags decides which experimental handler behaviors are enabled, so
+// they can be rolled out task by task.
+type FeatureFlags interface {
+	Enabled(taskName, flag string) bool
+}
+
+// FeatureFlagsFunc adapts a function to FeatureFlags, e.g. to pin flags in
+// tests.
+type FeatureFlagsFunc func(taskName, flag string) bool
+
+func (f FeatureFlagsFunc) Enabled(taskName, flag string) bool { return f(taskName, flag) }
+
+// featureFlags is the provider taskFlag consults. Replace it before the
+// workers start to take flags from elsewhere; by default every flag is off.
+var featureFlags FeatureFlags = envFeatureFlags{}
+
+// taskFlag reports whether flag is enabled for the task type of t.
+func taskFlag(t *model.Task, flag string) bool {
+	return featureFlags.Enabled(t.TaskDefName, flag)
+}
+
+// envFeatureFlags holds the flags of FEATURE_FLAGS, enabled for every task or
+// by task name.
+type envFeatureFlags struct {
+	all    map[string]bool
+	byTask map[string]map[string]bool
+}
+
+// parseFeatureFlags parses a comma separated list of flags, each enabled for
+// every task ("new-enrichment") or for one task
+// ("enrich_user_task:new-enrichment").
+func parseFeatureFlags(spec string) (envFeatureFlags, error) {
+	flags := envFeatureFlags{all: map[string]bool{}, byTask: map[string]map[string]bool{}}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		taskName, flag, scoped := strings.Cut(entry, ":")
+		if !scoped {
+			flags.all[entry] = true
+			continue
+		}
+		taskName, flag = strings.TrimSpace(taskName), strings.TrimSpace(flag)
+		if taskName == "" || flag == "" {
+			return envFeatureFlags{}, fmt.Errorf("malformed entry %q: want flag or task:flag", entry)
+		}
+		if flags.byTask[taskName] == nil {
+			flags.byTask[taskName] = map[string]bool{}
+		}
+		flags.byTask[taskName][flag] = true
+	}
+	return flags, nil
+}
+
+func (f envFeatureFlags) Enabled(taskName, flag string) bool {
+	return f.all[flag] || f.byTask[taskName][flag]
+}
+
+// String lists the enabled flags in FEATURE_FLAGS form, sorted.
+func (f envFeatureFlags) String() string {
+	var entries []string
+	for flag := range f.all {
+		entries = append(entries, flag)
+	}
+	for taskName, flags := range f.byTask {
+		for flag := range flags {
+			entries = append(entries, taskName+":"+flag)
+		}
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
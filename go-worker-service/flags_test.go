@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+func TestParseFeatureFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		task    string
+		flag    string
+		want    bool
+		wantStr string
+		wantErr bool
+	}{
+		{name: "empty", spec: "", task: "enrich_user_task", flag: "new-enrichment"},
+		{name: "enabled for every task", spec: "new-enrichment", task: "enrich_user_task", flag: "new-enrichment", want: true, wantStr: "new-enrichment"},
+		{name: "enabled for the task", spec: "enrich_user_task:new-enrichment", task: "enrich_user_task", flag: "new-enrichment", want: true, wantStr: "enrich_user_task:new-enrichment"},
+		{name: "enabled for another task", spec: "create_user_task:new-enrichment", task: "enrich_user_task", flag: "new-enrichment", wantStr: "create_user_task:new-enrichment"},
+		{name: "other flag", spec: "fast-path", task: "enrich_user_task", flag: "new-enrichment", wantStr: "fast-path"},
+		{
+			name:    "spaces and empty entries ignored",
+			spec:    " fast-path , enrich_user_task : new-enrichment ,,",
+			task:    "enrich_user_task",
+			flag:    "new-enrichment",
+			want:    true,
+			wantStr: "enrich_user_task:new-enrichment,fast-path",
+		},
+		{name: "missing task", spec: ":new-enrichment", wantErr: true},
+		{name: "missing flag", spec: "enrich_user_task:", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags, err := parseFeatureFlags(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want an error: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := flags.Enabled(tt.task, tt.flag); got != tt.want {
+				t.Errorf("Enabled(%s, %s) = %v, want %v", tt.task, tt.flag, got, tt.want)
+			}
+			if got := flags.String(); got != tt.wantStr {
+				t.Errorf("String() = %q, want %q", got, tt.wantStr)
+			}
+		})
+	}
+}
+
+func TestTaskFlag(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider FeatureFlags
+		task     string
+		want     bool
+	}{
+		{name: "default provider has every flag off", provider: envFeatureFlags{}, task: "enrich_user_task"},
+		{
+			name:     "stub provider asked by task name",
+			provider: FeatureFlagsFunc(func(taskName, flag string) bool { return taskName == "enrich_user_task" && flag == "new-enrichment" }),
+			task:     "enrich_user_task",
+			want:     true,
+		},
+		{
+			name:     "stub provider for another task",
+			provider: FeatureFlagsFunc(func(taskName, flag string) bool { return taskName == "enrich_user_task" && flag == "new-enrichment" }),
+			task:     "create_user_task",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := featureFlags
+			featureFlags = tt.provider
+			t.Cleanup(func() { featureFlags = prev })
+			if got := taskFlag(&model.Task{TaskDefName: tt.task}, "new-enrichment"); got != tt.want {
+				t.Errorf("taskFlag() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
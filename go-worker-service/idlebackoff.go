@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// idleBackoff stretches the poll interval of tasks whose polls keep coming
+// back empty; see SetIdleBackoff.
+type idleBackoff struct {
+	max    time.Duration
+	factor float64
+}
+
+// stretch returns interval after emptyPolls consecutive empty polls: interval
+// multiplied by factor for each of them, but no longer than max. Intervals
+// already longer than max are left as they are.
+func (b *idleBackoff) stretch(interval time.Duration, emptyPolls int) time.Duration {
+	if emptyPolls == 0 || interval >= b.max {
+		return interval
+	}
+	stretched := float64(interval) * math.Pow(b.factor, float64(emptyPolls))
+	if stretched >= float64(b.max) || interval <= 0 {
+		return b.max
+	}
+	return time.Duration(stretched)
+}
+
+// SetIdleBackoff makes each task sleep factor times longer after every
+// consecutive empty poll, up to max, and go back to its configured poll
+// interval as soon as a poll returns work. This cuts the polls an idle worker
+// sends Conductor. A max of 0 disables the backoff.
+//
+// The runner reports empty polls only by starting the next one, so the
+// interval is stretched one poll late: the first sleep after work stops is
+// always the configured one.
+func (s *supervisor) SetIdleBackoff(max time.Duration, factor float64) error {
+	if max > 0 && (factor <= 1 || math.IsInf(factor, 0) || math.IsNaN(factor)) {
+		return fmt.Errorf("invalid idle backoff factor %v: want a number above 1", factor)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.idleBackoff = nil
+	if max > 0 {
+		s.idleBackoff = &idleBackoff{max: max, factor: factor}
+	}
+	for taskName := range s.emptyPolls {
+		delete(s.emptyPolls, taskName)
+		if err := s.applyPollIntervalLocked(taskName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pollEmpty records that the last poll of taskName returned no task and
+// stretches its poll interval one step further.
+func (s *supervisor) pollEmpty(taskName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.idleBackoff == nil || s.workers[taskName] == nil {
+		return
+	}
+	if cur, err := s.runner.GetPollIntervalForTask(taskName); err == nil && cur >= s.idleBackoff.max {
+		return
+	}
+	s.emptyPolls[taskName]++
+	if err := s.applyPollIntervalLocked(taskName); err != nil {
+		log.Printf("Supervisor: failed to back off polling of idle %s: %v", taskName, err)
+	}
+}
+
+// pollReturnedTasks records that the last poll of taskName returned work and
+// puts its poll interval back to the configured one.
+func (s *supervisor) pollReturnedTasks(taskName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.emptyPolls[taskName] == 0 {
+		return
+	}
+	delete(s.emptyPolls, taskName)
+	if err := s.applyPollIntervalLocked(taskName); err != nil {
+		log.Printf("Supervisor: failed to reset poll interval of %s: %v", taskName, err)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// jsonVisitor replaces the value rv, held by the struct field f or, for map
+// entries, slice elements and the root, by none, before walkJSON walks it. It
+// reports whether it replaced the value.
+type jsonVisitor func(rv reflect.Value, f *reflect.StructField) (interface{}, bool)
+
+// walkJSON returns a copy of rv in the shape encoding/json encodes it: maps,
+// slices and plain values. Structs become maps keyed by their JSON field
+// names, with the fields of embedded structs promoted, fields tagged "-"
+// dropped and omitempty fields dropped when empty. Values implementing
+// json.Marshaler or encoding.TextMarshaler, such as times or enums with a
+// custom representation, are kept as they are to encode themselves. visit,
+// when not nil, sees every value first and may replace it.
+func walkJSON(rv reflect.Value, visit jsonVisitor) interface{} {
+	return walkJSONField(rv, nil, visit)
+}
+
+func walkJSONField(rv reflect.Value, f *reflect.StructField, visit jsonVisitor) interface{} {
+	if visit != nil {
+		if v, ok := visit(rv, f); ok {
+			return v
+		}
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	if marshalsItself(rv.Type()) {
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil
+		}
+		return rv.Interface()
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return walkJSONField(rv.Elem(), nil, visit)
+	case reflect.Struct:
+		out := make(map[string]interface{}, rv.NumField())
+		walkJSONStruct(rv, visit, out)
+		return out
+	case reflect.Map:
+		if rv.IsNil() || rv.Type().Key().Kind() != reflect.String {
+			return rv.Interface()
+		}
+		out := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			out[iter.Key().String()] = walkJSONField(iter.Value(), nil, visit)
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return rv.Interface()
+		}
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			out[i] = walkJSONField(rv.Index(i), nil, visit)
+		}
+		return out
+	default:
+		return rv.Interface()
+	}
+}
+
+// walkJSONStruct adds the fields of the struct rv to out. The fields of
+// embedded structs without a JSON name are promoted, losing to the fields of
+// the same name declared closer to rv, as with encoding/json.
+func walkJSONStruct(rv reflect.Value, visit jsonVisitor, out map[string]interface{}) {
+	rt := rv.Type()
+	var embedded []reflect.Value
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && !marshalsItself(f.Type) {
+				if fv := rv.Field(i); fv.Kind() != reflect.Ptr {
+					embedded = append(embedded, fv)
+				} else if f.IsExported() && !fv.IsNil() {
+					embedded = append(embedded, fv.Elem())
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fv := rv.Field(i)
+		if hasJSONOption(opts, "omitempty") && isEmptyJSONValue(fv) {
+			continue
+		}
+		out[name] = walkJSONField(fv, &f, visit)
+	}
+	for _, fv := range embedded {
+		promoted := make(map[string]interface{}, fv.NumField())
+		walkJSONStruct(fv, visit, promoted)
+		for name, v := range promoted {
+			if _, ok := out[name]; !ok {
+				out[name] = v
+			}
+		}
+	}
+}
+
+// marshalsItself reports whether values of t encode themselves to JSON, so
+// their fields must not be walked.
+func marshalsItself(t reflect.Type) bool {
+	return t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType)
+}
+
+func hasJSONOption(opts, option string) bool {
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyJSONValue reports whether omitempty drops rv, which unlike
+// reflect.Value.IsZero never holds for structs.
+func isEmptyJSONValue(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Ptr:
+		return rv.IsZero()
+	}
+	return false
+}
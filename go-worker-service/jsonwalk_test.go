@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// priority is an enum encoding itself by name.
+type priority int
+
+func (p priority) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]string{"low", "high"}[p])
+}
+
+// region is an enum encoding itself as text.
+type region struct{ code string }
+
+func (r region) MarshalText() ([]byte, error) { return []byte(r.code), nil }
+
+type audited struct {
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type account struct {
+	audited
+	ID       int        `json:"id"`
+	Name     string     `json:"name,omitempty"`
+	Token    string     `json:"token" conductor:"secret"`
+	Priority priority   `json:"priority"`
+	Region   region     `json:"region"`
+	Tags     []string   `json:"tags,omitempty"`
+	Internal string     `json:"-"`
+	Expires  *time.Time `json:"expires,omitempty"`
+}
+
+// shadowing declares a field its embedded struct also declares.
+type shadowing struct {
+	audited
+	CreatedAt string `json:"created_at"`
+}
+
+func TestEpochMillisValue(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		in   interface{}
+		want interface{}
+	}{
+		{
+			name: "MarshalJSON and MarshalText enums kept",
+			in:   account{ID: 1, Priority: 1, Region: region{"eu"}},
+			want: map[string]interface{}{
+				"created_at": time.Time{}.UnixMilli(), "id": 1, "token": "", "priority": priority(1), "region": region{"eu"},
+			},
+		},
+		{
+			name: "embedded struct promoted and omitempty dropped",
+			in:   account{audited: audited{CreatedAt: ts}, Name: "acme", Expires: &ts},
+			want: map[string]interface{}{
+				"created_at": ts.UnixMilli(), "id": 0, "name": "acme", "token": "", "priority": priority(0), "region": region{}, "expires": ts.UnixMilli(),
+			},
+		},
+		{
+			name: "outer field shadows embedded one",
+			in:   shadowing{audited: audited{CreatedAt: ts}, CreatedAt: "outer"},
+			want: map[string]interface{}{"created_at": "outer"},
+		},
+		{
+			name: "nested map and slice times",
+			in:   map[string]interface{}{"at": []interface{}{ts}},
+			want: map[string]interface{}{"at": []interface{}{ts.UnixMilli()}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := epochMillisValue(reflect.ValueOf(tt.in)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("epochMillisValue() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		in   interface{}
+		want interface{}
+	}{
+		{
+			name: "secret field redacted, time and enums kept",
+			in:   account{audited: audited{CreatedAt: ts}, ID: 1, Token: "s3cr3t", Priority: 1},
+			want: map[string]interface{}{
+				"created_at": ts, "id": 1, "token": redactedValue, "priority": priority(1), "region": region{},
+			},
+		},
+		{
+			name: "secret field of embedded struct redacted",
+			in: struct {
+				account
+				Extra string `json:"extra,omitempty"`
+			}{account: account{Token: "s3cr3t"}},
+			want: map[string]interface{}{
+				"created_at": time.Time{}, "id": 0, "token": redactedValue, "priority": priority(0), "region": region{},
+			},
+		},
+		{
+			name: "secret field inside a map",
+			in:   map[string]interface{}{"account": &account{Token: "s3cr3t", Tags: []string{"a"}}},
+			want: map[string]interface{}{"account": map[string]interface{}{
+				"created_at": time.Time{}, "id": 0, "token": redactedValue, "priority": priority(0), "region": region{}, "tags": []interface{}{"a"},
+			}},
+		},
+		{name: "nil", in: nil, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactSecrets(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("redactSecrets() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,13 +1,25 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/conductor-sdk/conductor-go/sdk/client"
+	sdklog "github.com/conductor-sdk/conductor-go/sdk/log"
 	"github.com/conductor-sdk/conductor-go/sdk/model"
 	"github.com/conductor-sdk/conductor-go/sdk/settings"
 	"github.com/conductor-sdk/conductor-go/sdk/worker"
@@ -16,6 +28,82 @@ import (
 
 var db *sql.DB
 
+// globalSlots bounds the handler executions running at once across all tasks
+// (WORKER_GLOBAL_CONCURRENCY). Nil means unbounded.
+var globalSlots *taskSlots
+
+// globalRateLimit bounds the rate of handler executions across all tasks
+// (WORKER_GLOBAL_RATE_LIMIT). Nil means unlimited.
+var globalRateLimit *tokenBucket
+
+// stateWriteSlots bounds the worker_state writes running at once
+// (STATE_WRITE_CONCURRENCY). Nil leaves them unbounded.
+var stateWriteSlots chan struct{}
+
+// workerID is the hostname, or an id generated when it is unavailable.
+var workerID string
+
+// generatedWorkerID replaces the empty worker id the SDK reports when the
+// hostname is unavailable. Empty when the hostname is used.
+var generatedWorkerID string
+
+// recorder captures every task and its result when RECORD_FILE is set. Nil
+// means no recording.
+var recorder *taskRecorder
+
+// dedupe skips tasks delivered twice to this process (TASK_DEDUPE_TTL_MS). Nil
+// means no deduplication.
+var dedupe *taskDedupe
+
+// inputDefaults fills in task input keys missing from every task
+// (TASK_INPUT_DEFAULTS). Nil means no defaults.
+var inputDefaults map[string]interface{}
+
+// allowedEnterprises limits the enterprises tasks may name in entp_name
+// (ALLOWED_ENTERPRISES). Nil allows every enterprise.
+var allowedEnterprises map[string]bool
+
+// recordStartedDefault and recordStartedSkip decide which tasks record their
+// STARTED state (RECORD_STARTED and RECORD_STARTED_SKIP_TASKS).
+var (
+	recordStartedDefault = true
+	recordStartedSkip    = map[string]bool{}
+)
+
+func recordStarted(taskName string) bool {
+	return recordStartedDefault && !recordStartedSkip[taskName]
+}
+
+// outputKeyMap renames the output keys of each task name in it
+// (OUTPUT_KEY_MAP). Nil renames none.
+var outputKeyMap map[string]map[string]string
+
+// outputCompressionTasks limits output compression to the task names in it
+// (OUTPUT_COMPRESSION_TASKS). Empty compresses the outputs of every task.
+var outputCompressionTasks = map[string]bool{}
+
+// errorPolicy decides the status of every task from its handler outcome
+// (ERROR_POLICY).
+var errorPolicy statusMapper = defaultStatusMapper
+
+// shutdownTimeout bounds how long each task may drain during shutdown.
+const shutdownTimeout = 30 * time.Second
+
+// schemaNamePattern restricts DB_SCHEMA to plain lowercase identifiers of at
+// most 63 bytes. The name is passed unquoted in the search_path of the
+// connection string, where Postgres folds it to lowercase, so only a lowercase
+// name finds the schema created under its quoted name.
+var schemaNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// dbSchema returns the schema named by DB_SCHEMA, public by default.
+func dbSchema() (string, error) {
+	schema := getEnv("DB_SCHEMA", "public")
+	if !schemaNamePattern.MatchString(schema) {
+		return "", fmt.Errorf("invalid DB_SCHEMA %q: want a lowercase name of letters, digits and underscores", schema)
+	}
+	return schema, nil
+}
+
 // getEnv returns the value of the environment variable if set, otherwise the provided default.
 func getEnv(key, def string) string {
 	if v := os.Getenv(key); v != "" {
@@ -24,16 +112,37 @@ func getEnv(key, def string) string {
 	return def
 }
 
-// initDB initializes the Postgres connection and sets up tables.
-func initDB() {
-	// Read DB configuration from environment with sensible defaults
-	host := getEnv("DB_HOST", "localhost")
-	port := getEnv("DB_PORT", "5432")
-	user := getEnv("DB_USER", "user")
-	password := getEnv("DB_PASSWORD", "password")
-	dbname := getEnv("DB_NAME", "conductor")
+// getEnvInt returns the integer value of the environment variable if set, otherwise the provided default.
+// An unparsable value stops the worker so misconfiguration is caught at startup.
+func getEnvInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("Invalid %s %q: %v", key, v, err)
+	}
+	return n
+}
+
+// getEnvFloat returns the float value of the environment variable if set, otherwise the provided default.
+// An unparsable value stops the worker so misconfiguration is caught at startup.
+func getEnvFloat(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Fatalf("Invalid %s %q: %v", key, v, err)
+	}
+	return f
+}
 
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
+// openDB opens the Postgres connection without touching the schema.
+func openDB() {
+	connStr, _ := dbConnString()
 
 	var err error
 	db, err = sql.Open("postgres", connStr)
@@ -43,19 +152,33 @@ func initDB() {
 	if err = db.Ping(); err != nil {
 		log.Fatalf("Error connecting to database: %v", err)
 	}
+}
+
+// initDB initializes the Postgres connection and sets up tables.
+func initDB() {
+	openDB()
+	_, schema := dbConnString()
+
+	if _, err := db.Exec("CREATE SCHEMA IF NOT EXISTS " + pq.QuoteIdentifier(schema)); err != nil {
+		log.Fatalf("Error creating schema %s: %v", schema, err)
+	}
 
 	// Set up tables
-	_, err = db.Exec(`
+	_, err := db.Exec(`
         CREATE TABLE IF NOT EXISTS enterprise (
             id SERIAL PRIMARY KEY,
             name VARCHAR(255) UNIQUE NOT NULL,
-            details TEXT
+            details TEXT,
+            active BOOLEAN NOT NULL DEFAULT true
         );
+        ALTER TABLE enterprise ADD COLUMN IF NOT EXISTS active BOOLEAN NOT NULL DEFAULT true;
         CREATE TABLE IF NOT EXISTS "user" (
             id SERIAL PRIMARY KEY,
             enterprise_id INT REFERENCES enterprise(id),
-            username VARCHAR(255) UNIQUE NOT NULL
+            username VARCHAR(255) UNIQUE NOT NULL,
+            details JSONB
         );
+        ALTER TABLE "user" ADD COLUMN IF NOT EXISTS details JSONB;
         CREATE TABLE IF NOT EXISTS worker_state (
             task_id VARCHAR(128) PRIMARY KEY,
             workflow_id VARCHAR(128),
@@ -65,7 +188,21 @@ func initDB() {
             output JSONB,
             error TEXT,
             created_at TIMESTAMPTZ DEFAULT NOW(),
-            updated_at TIMESTAMPTZ DEFAULT NOW()
+            updated_at TIMESTAMPTZ DEFAULT NOW(),
+            started_at TIMESTAMPTZ,
+            finished_at TIMESTAMPTZ
+        );
+        ALTER TABLE worker_state ADD COLUMN IF NOT EXISTS started_at TIMESTAMPTZ;
+        ALTER TABLE worker_state ADD COLUMN IF NOT EXISTS finished_at TIMESTAMPTZ;
+        CREATE TABLE IF NOT EXISTS worker_dead_letter (
+            task_id VARCHAR(128) PRIMARY KEY,
+            workflow_id VARCHAR(128) NOT NULL,
+            task_type VARCHAR(255) NOT NULL,
+            input JSONB,
+            error TEXT NOT NULL,
+            retry_count INT NOT NULL DEFAULT 0,
+            created_at TIMESTAMPTZ DEFAULT NOW(),
+            replayed_at TIMESTAMPTZ
         );
         CREATE OR REPLACE FUNCTION set_updated_at()
         RETURNS TRIGGER AS $$
@@ -75,7 +212,7 @@ func initDB() {
         END;
         $$ LANGUAGE plpgsql;
         DO $$ BEGIN
-          IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'worker_state_set_updated_at') THEN
+          IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'worker_state_set_updated_at' AND tgrelid = 'worker_state'::regclass) THEN
             CREATE TRIGGER worker_state_set_updated_at
             BEFORE UPDATE ON worker_state
             FOR EACH ROW EXECUTE FUNCTION set_updated_at();
@@ -85,91 +222,298 @@ func initDB() {
 	if err != nil {
 		log.Fatalf("Error creating tables: %v", err)
 	}
-	log.Println("Database connection successful and tables checked.")
+	log.Printf("Database connection successful and tables checked in schema %s.", schema)
 }
 
-// createEnterpriseWorker implements the 'create_enterprise_task'
-// recordWorkerState persists the worker task state in Postgres
-func recordWorkerState(t *model.Task, status string, output map[string]interface{}, errText *string) {
+// dbConnString returns the Postgres connection string and schema configured
+// in the environment.
+func dbConnString() (connStr, schema string) {
+	// Read DB configuration from environment with sensible defaults
+	host := getEnv("DB_HOST", "localhost")
+	port := getEnv("DB_PORT", "5432")
+	user := getEnv("DB_USER", "user")
+	password := getEnv("DB_PASSWORD", "password")
+	dbname := getEnv("DB_NAME", "conductor")
+	// Tables live in DB_SCHEMA so several tenants can share one database
+	schema, err := dbSchema()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	connStr = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable search_path=%s", host, port, user, password, dbname, schema)
+	return connStr, schema
+}
+
+// recordWorkerState persists the worker task state in Postgres, along with
+// the time the handler started and, for any state but STARTED, the time it
+// finished, both by the worker's clock.
+func recordWorkerState(t *model.Task, status string, started time.Time, output map[string]interface{}, errText *string) {
 	if db == nil || t == nil {
 		return
 	}
 	inBytes, _ := json.Marshal(t.InputData)
-	var outStr *string
-	if output != nil {
-		ob, _ := json.Marshal(output)
-		s := string(ob)
-		outStr = &s
+	outStr := workerStateOutput(output)
+	var finished *time.Time
+	if status != "STARTED" {
+		now := time.Now()
+		finished = &now
 	}
 	// Build params
-	params := []interface{}{t.TaskId, t.WorkflowInstanceId, t.TaskType, status, string(inBytes), outStr, errText}
-	_, e := db.Exec(`
-		INSERT INTO worker_state (task_id, workflow_id, task_type, status, input, output, error, updated_at)
-		VALUES ($1,$2,$3,$4,$5::jsonb,$6::jsonb,$7, NOW())
+	params := []interface{}{t.TaskId, t.WorkflowInstanceId, t.TaskType, status, string(inBytes), outStr, errText, started, finished}
+	// Bound the connections state writes take from the handlers' own queries
+	if stateWriteSlots != nil {
+		stateWriteSlots <- struct{}{}
+		defer func() { <-stateWriteSlots }()
+	}
+	_, e := taskDB(t).ExecContext(context.Background(), `
+		INSERT INTO worker_state (task_id, workflow_id, task_type, status, input, output, error, updated_at, started_at, finished_at)
+		VALUES ($1,$2,$3,$4,$5::jsonb,$6::jsonb,$7, NOW(), $8, $9)
 		ON CONFLICT (task_id) DO UPDATE SET
 		  status=EXCLUDED.status,
 		  output=EXCLUDED.output,
 		  error=EXCLUDED.error,
-		  updated_at=NOW()
+		  updated_at=NOW(),
+		  started_at=EXCLUDED.started_at,
+		  finished_at=EXCLUDED.finished_at
 	`, params...)
 	if e != nil {
+		stateHealth.recordFailure()
 		log.Printf("failed to record worker state for task %s: %v", t.TaskId, e)
+		return
 	}
+	stateHealth.recordSuccess()
 }
 
-// withStateLogging wraps a worker handler to record state transitions
+// workerStateOutput returns the JSON of output, redacted by redactOutput, for
+// the output column of worker_state, or nil without an output.
+func workerStateOutput(output map[string]interface{}) *string {
+	if output == nil {
+		return nil
+	}
+	ob, _ := json.Marshal(redactOutput(output))
+	s := string(ob)
+	return &s
+}
+
+// panicReportLimit bounds the length of the panic reports written to the
+// error column of worker_state.
+const panicReportLimit = 8192
+
+// panicReport formats a recovered panic value and the stack it unwound from,
+// truncated to panicReportLimit bytes.
+func panicReport(v interface{}, stack []byte) string {
+	report := fmt.Sprintf("panic: %v\n\n%s", v, stack)
+	if len(report) > panicReportLimit {
+		report = report[:panicReportLimit] + "\n... (truncated)"
+	}
+	return report
+}
+
+// withStateLogging wraps a worker handler to record state transitions.
+// A handler returning (nil, nil) is treated as COMPLETED with an empty output.
+// The STARTED state is skipped for the tasks recordStarted rejects, halving
+// their state writes.
+//
+// A panicking handler is recorded as PANIC with the panic message and stack
+// before the panic continues to the SDK, which logs it and leaves the task to
+// Conductor's response timeout.
 func withStateLogging(fn func(*model.Task) (interface{}, error)) func(*model.Task) (interface{}, error) {
 	return func(t *model.Task) (interface{}, error) {
-		recordWorkerState(t, "STARTED", nil, nil)
+		started := time.Now()
+		if recordStarted(t.TaskDefName) {
+			recordWorkerState(t, "STARTED", started, nil, nil)
+		}
+		defer func() {
+			if v := recover(); v != nil {
+				report := panicReport(v, debug.Stack())
+				recordWorkerState(t, "PANIC", started, nil, &report)
+				panic(v)
+			}
+		}()
 		res, err := fn(t)
 		if err != nil {
 			errStr := err.Error()
-			recordWorkerState(t, "FAILED", nil, &errStr)
+			recordWorkerState(t, "FAILED", started, nil, &errStr)
 			return nil, err
 		}
+		if res == nil {
+			res = map[string]interface{}{}
+		}
+		status := "COMPLETED"
 		var out map[string]interface{}
-		if m, ok := res.(map[string]interface{}); ok {
-			out = m
+		switch r := res.(type) {
+		case map[string]interface{}:
+			out = r
+		case *model.TaskResult:
+			status = string(r.Status)
+			out = r.OutputData
+		default:
+			out, _ = model.ConvertToMap(r)
 		}
-		recordWorkerState(t, "COMPLETED", out, nil)
+		recordWorkerState(t, status, started, out, nil)
 		return res, nil
 	}
 }
 
+// wrapHandler applies the configured middleware chain to a worker handler.
+func wrapHandler(fn func(*model.Task) (interface{}, error)) func(*model.Task) (interface{}, error) {
+	if getEnv("OUTPUT_VALIDATION", "true") == "true" {
+		fn = withOutputValidation(fn)
+	}
+	if getEnv("OUTPUT_TIME_FORMAT", "rfc3339") == "epoch_millis" {
+		fn = withEpochMillisTimes(fn)
+	}
+	if outputKeyMap != nil {
+		fn = withOutputKeyMap(outputKeyMap, fn)
+	}
+	fn = withCancellation(fn)
+	if allowedEnterprises != nil {
+		fn = withInputMiddleware(fn, enterpriseAllowlist(allowedEnterprises))
+	}
+	if inputDefaults != nil {
+		fn = withInputDefaults(inputDefaults, fn)
+	}
+	h := withStatusMapping(errorPolicy, withInputDecompression(fn))
+	if getEnv("DEAD_LETTER", "true") == "true" {
+		h = withDeadLetter(h)
+	}
+	h = withStateLogging(h)
+	if getEnv("AUDIT_LOG", "false") == "true" {
+		h = withAuditLog(log.New(os.Stdout, "", log.LstdFlags), h)
+	}
+	if threshold := getEnvInt("OUTPUT_COMPRESSION_THRESHOLD", 0); threshold > 0 {
+		h = withOutputCompression(threshold, outputCompressionTasks, h)
+	}
+	h = withExecutionStart(h)
+	if globalSlots != nil {
+		h = withConcurrencyLimit(globalSlots, h)
+	}
+	if globalRateLimit != nil {
+		h = withRateLimit(globalRateLimit, h)
+	}
+	h = withTaskLogs(h)
+	if getEnv("RESULT_METADATA", "false") == "true" {
+		h = withResultDecorator(workerMetadataDecorator(workerID), h)
+	}
+	if generatedWorkerID != "" {
+		h = withResultDecorator(workerIDDecorator(generatedWorkerID), h)
+	}
+	if recorder != nil {
+		h = withRecording(recorder, h)
+	}
+	if dedupe != nil {
+		h = withDedupe(dedupe, h)
+	}
+	return h
+}
+
+func init() {
+	handlers.Register("create_enterprise_task", createEnterpriseWorker)
+	handlers.Register("create_user_task", onboardEmployeeWorker)
+	handlers.After("create_user_task", "create_enterprise_task")
+	// db is only opened by main, so the transaction is wrapped per task
+	handlers.Register("create_enterprise_and_user_task", func(t *model.Task) (interface{}, error) {
+		return withTx(db, createEnterpriseAndUserWorker)(t)
+	})
+}
+
+// createEnterpriseWorker implements the 'create_enterprise_task'.
 func createEnterpriseWorker(t *model.Task) (interface{}, error) {
+	logger := taskLogger(t)
 	entpName, ok := t.InputData["entp_name"].(string)
 	if !ok || entpName == "" {
 		return nil, fmt.Errorf("missing entp_name in task input")
 	}
 
+	// A single upsert returns the id whether the enterprise is new or not;
+	// xmax is only zero for a freshly inserted row
 	var entpID int
-	err := db.QueryRow("INSERT INTO enterprise (name, details) VALUES ($1, $2) RETURNING id", entpName, "Enterprise Details Here").Scan(&entpID)
+	var active, created bool
+	err := taskDB(t).QueryRowContext(taskContext(t), `INSERT INTO enterprise (name, details) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id, active, xmax = 0`, entpName, "Enterprise Details Here").Scan(&entpID, &active, &created)
 	if err != nil {
-		// If insert failed due to unique constraint, fetch existing enterprise id
-		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-			if qerr := db.QueryRow("SELECT id FROM enterprise WHERE name = $1", entpName).Scan(&entpID); qerr != nil {
-				log.Printf("Worker 1 FAILED selecting existing enterprise after duplicate error: %v", qerr)
-				return nil, fmt.Errorf("failed to find existing enterprise after duplicate error: %v", qerr)
-			}
-			log.Printf("Worker 1: Enterprise '%s' already exists with ID: %d", entpName, entpID)
-			return map[string]interface{}{"enterprise_id": entpID}, nil
-		}
-		log.Printf("Worker 1 FAILED: %v", err)
-		return nil, fmt.Errorf("failed to create enterprise: %v", err)
+		logger.Printf("Worker 1 FAILED: %v", err)
+		return nil, &dbError{Op: "create enterprise", Err: err}
+	}
+	if !active {
+		logger.Printf("Worker 1: Enterprise '%s' (%d) is inactive", entpName, entpID)
+		return nil, model.NewNonRetryableError(fmt.Errorf("enterprise '%s' is inactive", entpName))
 	}
 
-	log.Printf("Worker 1: Enterprise '%s' created with ID: %d", entpName, entpID)
+	if created {
+		logger.Printf("Worker 1: Enterprise '%s' created with ID: %d", entpName, entpID)
+		taskLog(t, fmt.Sprintf("Enterprise '%s' created with ID %d", entpName, entpID))
+	} else {
+		logger.Printf("Worker 1: Enterprise '%s' already exists with ID: %d", entpName, entpID)
+		taskLog(t, fmt.Sprintf("Enterprise '%s' already exists, reusing ID %d", entpName, entpID))
+	}
 	return map[string]interface{}{"enterprise_id": entpID}, nil
 }
 
+// resolveEnterpriseID returns the enterprise_id from the task input. Workflows
+// that only wire entp_name through are supported by looking the id up by name.
+// Inactive enterprises are rejected with a terminal error.
+func resolveEnterpriseID(t *model.Task) (int, error) {
+	logger := taskLogger(t)
+	ctx := taskContext(t)
+	if v, present := t.InputData["enterprise_id"]; present && v != nil {
+		entpIDFloat, ok := v.(float64)
+		if !ok {
+			return 0, model.NewNonRetryableError(fmt.Errorf("invalid enterprise_id in task input"))
+		}
+		entpID := int(entpIDFloat)
+		var active bool
+		err := taskDB(t).QueryRowContext(ctx, "SELECT active FROM enterprise WHERE id = $1", entpID).Scan(&active)
+		if err == sql.ErrNoRows {
+			return 0, model.NewNonRetryableError(fmt.Errorf("enterprise %d not found", entpID))
+		} else if err != nil {
+			return 0, fmt.Errorf("failed to look up enterprise %d: %v", entpID, err)
+		}
+		if !active {
+			return 0, model.NewNonRetryableError(fmt.Errorf("enterprise %d is inactive", entpID))
+		}
+		return entpID, nil
+	}
+	entpName, ok := t.InputData["entp_name"].(string)
+	if !ok || entpName == "" {
+		return 0, model.NewNonRetryableError(fmt.Errorf("missing enterprise_id or entp_name in task input"))
+	}
+	var entpID int
+	var active bool
+	err := taskDB(t).QueryRowContext(ctx, "SELECT id, active FROM enterprise WHERE name = $1", entpName).Scan(&entpID, &active)
+	if err == sql.ErrNoRows {
+		return 0, model.NewNonRetryableError(fmt.Errorf("enterprise '%s' not found", entpName))
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to look up enterprise '%s': %v", entpName, err)
+	}
+	if !active {
+		return 0, model.NewNonRetryableError(fmt.Errorf("enterprise '%s' is inactive", entpName))
+	}
+	logger.Printf("Worker 2: Resolved enterprise '%s' to ID: %d", entpName, entpID)
+	return entpID, nil
+}
+
+// createUserOutput is the output of the 'create_user_task'.
+type createUserOutput struct {
+	UserID int `json:"user_id"`
+}
+
+// Validate rejects outputs without a database-assigned user id.
+func (o createUserOutput) Validate() error {
+	if o.UserID <= 0 {
+		return fmt.Errorf("user_id must be positive, got %d", o.UserID)
+	}
+	return nil
+}
+
 // onboardEmployeeWorker implements the 'create_user_task'
 func onboardEmployeeWorker(t *model.Task) (interface{}, error) {
+	logger := taskLogger(t)
 	// Get inputs from the workflow
-	entpIDFloat, ok := t.InputData["enterprise_id"].(float64)
-	if !ok {
-		return nil, fmt.Errorf("missing or invalid enterprise_id in task input")
+	entpID, err := resolveEnterpriseID(t)
+	if err != nil {
+		return nil, err
 	}
-	entpID := int(entpIDFloat)
 
 	userName, ok := t.InputData["user_name"].(string)
 	if !ok || userName == "" {
@@ -177,31 +521,453 @@ func onboardEmployeeWorker(t *model.Task) (interface{}, error) {
 	}
 
 	var userID int
-	err := db.QueryRow(`INSERT INTO "user" (enterprise_id, username) VALUES ($1, $2) RETURNING id`, entpID, userName).Scan(&userID)
+	err = taskDB(t).QueryRowContext(taskContext(t), `INSERT INTO "user" (enterprise_id, username) VALUES ($1, $2) RETURNING id`, entpID, userName).Scan(&userID)
 	if err != nil {
-		log.Printf("Worker 2 FAILED: %v", err)
+		logger.Printf("Worker 2 FAILED: %v", err)
 		return nil, fmt.Errorf("failed to create user: %v", err)
 	}
 
-	log.Printf("Worker 2: User '%s' created with ID: %d in Enterprise %d", userName, userID, entpID)
-	return map[string]interface{}{"user_id": userID}, nil
+	logger.Printf("Worker 2: User '%s' created with ID: %d in Enterprise %d", userName, userID, entpID)
+	return createUserOutput{UserID: userID}, nil
+}
+
+// enterpriseUserInput is the input of 'create_enterprise_and_user_task'.
+type enterpriseUserInput struct {
+	EntpName string `json:"entp_name"`
+	UserName string `json:"user_name"`
+}
+
+// createEnterpriseAndUserWorker implements the 'create_enterprise_and_user_task':
+// it creates the enterprise, unless it exists, and the user in one transaction
+// so a failed user insert leaves no new enterprise behind.
+func createEnterpriseAndUserWorker(ctx context.Context, t *model.Task) (interface{}, error) {
+	logger := taskLogger(t)
+	tx, ok := TxFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("no transaction in context")
+	}
+	var in enterpriseUserInput
+	if err := bindInput(t, &in); err != nil {
+		return nil, err
+	}
+	entpName, userName := in.EntpName, in.UserName
+	if entpName == "" {
+		return nil, fmt.Errorf("missing entp_name in task input")
+	}
+	if userName == "" {
+		return nil, fmt.Errorf("missing user_name in task input")
+	}
+
+	// A unique violation would abort the transaction, so resolve existing
+	// enterprises with ON CONFLICT instead of retrying with a SELECT.
+	var entpID int
+	var active bool
+	err := tx.QueryRowContext(ctx, `INSERT INTO enterprise (name, details) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id, active`, entpName, "Enterprise Details Here").Scan(&entpID, &active)
+	if err != nil {
+		logger.Printf("Worker 3 FAILED creating enterprise: %v", err)
+		return nil, &dbError{Op: "create enterprise", Err: err}
+	}
+	if !active {
+		logger.Printf("Worker 3: Enterprise '%s' (%d) is inactive", entpName, entpID)
+		return nil, model.NewNonRetryableError(fmt.Errorf("enterprise '%s' is inactive", entpName))
+	}
+
+	var userID int
+	err = tx.QueryRowContext(ctx, `INSERT INTO "user" (enterprise_id, username) VALUES ($1, $2) RETURNING id`, entpID, userName).Scan(&userID)
+	if err != nil {
+		logger.Printf("Worker 3 FAILED creating user: %v", err)
+		return nil, &dbError{Op: "create user", Err: err}
+	}
+
+	logger.Printf("Worker 3: User '%s' created with ID: %d in Enterprise '%s' (%d)", userName, userID, entpName, entpID)
+	return map[string]interface{}{"enterprise_id": entpID, "user_id": userID}, nil
+}
+
+// replay runs the recorded tasks in path against the handler of their task
+// type, wrapped like the workers', prints the differences and returns the
+// process exit code. The handlers query the database as usual but within a
+// transaction that is rolled back at the end, so replaying changes no data.
+// It needs the database: the outputs compared are mostly ids it assigns.
+func replay(path string, handlers map[string]model.ExecuteTaskFunction) int {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("Replay failed: %v", err)
+		return 1
+	}
+	defer tx.Rollback()
+	results, err := ReplayFile(path, func(t *model.Task) (interface{}, error) {
+		fn, ok := handlers[t.TaskDefName]
+		if !ok {
+			return nil, fmt.Errorf("no handler for task %s", t.TaskDefName)
+		}
+		return replayInTx(tx, t, fn)
+	})
+	if err != nil {
+		log.Printf("Replay failed: %v", err)
+		return 1
+	}
+	failed := 0
+	for _, r := range results {
+		switch {
+		case r.Error != "":
+			failed++
+			log.Printf("Replay: %s %s failed: %s", r.TaskType, r.TaskID, r.Error)
+		case len(r.Diffs) > 0:
+			failed++
+			log.Printf("Replay: %s %s result differs:", r.TaskType, r.TaskID)
+			for _, d := range r.Diffs {
+				log.Printf("  %s", d)
+			}
+		}
+	}
+	log.Printf("Replay: %d task(s), %d mismatch(es)", len(results), failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
 }
 
 func main() {
-	// Initialize DB connection (reads env vars or uses defaults)
-	initDB()
+	replayPath := flag.String("replay", "", "replay the tasks recorded in this file (see RECORD_FILE) against the handlers and exit")
+	selfTest := flag.Bool("selftest", false, "check the database, Conductor and polling of every task, print a report and exit")
+	flag.Parse()
+
+	// Initialize DB connection (reads env vars or uses defaults). The
+	// self-test checks the database itself and leaves the tables alone, and
+	// replays only read and roll back.
+	switch {
+	case *replayPath != "":
+		// The handlers' outputs are the rows and ids Postgres gives them, so
+		// there is nothing to compare without the database they were
+		// recorded against
+		if os.Getenv("DB_HOST") == "" {
+			log.Fatal("-replay runs the handlers against the database they were recorded with: set DB_HOST (and DB_PORT, DB_USER, DB_PASSWORD, DB_NAME, DB_SCHEMA as needed) to a copy of it")
+		}
+		openDB()
+	case !*selfTest:
+		initDB()
+	}
+
+	id, generated := resolveWorkerID(os.Hostname)
+	workerID = id
+	if generated {
+		generatedWorkerID = id
+	}
+
+	redactedOutputKeys = parseRedactKeys(getEnv("REDACT_OUTPUT_KEYS", ""))
+	recordStartedDefault = getEnv("RECORD_STARTED", "true") == "true"
+	for _, taskName := range strings.Split(getEnv("RECORD_STARTED_SKIP_TASKS", ""), ",") {
+		if taskName = strings.TrimSpace(taskName); taskName != "" {
+			recordStartedSkip[taskName] = true
+		}
+	}
+	if n := getEnvInt("STATE_WRITE_CONCURRENCY", 4); n > 0 {
+		stateWriteSlots = make(chan struct{}, n)
+	}
+	stateHealth.threshold = int64(getEnvInt("STATE_WRITE_FAILURE_THRESHOLD", 5))
+	if n := getEnvInt("WORKER_GLOBAL_CONCURRENCY", 0); n > 0 {
+		globalSlots = newTaskSlots(n, getEnv("TASK_PRIORITY_ORDERING", "false") == "true")
+	}
+	tags, err := parseWorkerTags(getEnv("WORKER_TAGS", ""))
+	if err != nil {
+		log.Fatalf("Invalid WORKER_TAGS: %v", err)
+	}
+	workerTags = tags
+	if len(workerTags) > 0 {
+		log.Printf("Worker tags: %s", strings.Join(tagFields(workerTags), " "))
+	}
+	switch mode := getEnv("UNKNOWN_INPUT_VERSION", "current"); mode {
+	case "current":
+	case "fail":
+		inputBinder.strict = true
+	default:
+		log.Fatalf("Invalid UNKNOWN_INPUT_VERSION %q: want current or fail", mode)
+	}
+	if spec := getEnv("FEATURE_FLAGS", ""); spec != "" {
+		flags, err := parseFeatureFlags(spec)
+		if err != nil {
+			log.Fatalf("Invalid FEATURE_FLAGS: %v", err)
+		}
+		featureFlags = flags
+		log.Printf("Feature flags: %s", flags)
+	}
+	switch naming := getEnv("INPUT_FIELD_NAMING", "json"); naming {
+	case "json":
+	case "snake_case":
+		inputBinder.current = snakeCaseBinder{next: inputBinder.current}
+	default:
+		log.Fatalf("Invalid INPUT_FIELD_NAMING %q: want json or snake_case", naming)
+	}
+	if spec := getEnv("LEGACY_INPUT_KEYS", ""); spec != "" {
+		renames, err := parseLegacyInputKeys(spec)
+		if err != nil {
+			log.Fatalf("Invalid LEGACY_INPUT_KEYS: %v", err)
+		}
+		for version, keys := range renames {
+			inputBinder.Register(version, renamingBinder{renames: keys, next: inputBinder.current})
+		}
+	}
+	if ttl := getEnvInt("CALLBACK_TOKEN_TTL_MS", 0); ttl > 0 {
+		callbacks = newCallbackStore(time.Duration(ttl) * time.Millisecond)
+	}
+	if ttl := getEnvInt("TASK_DEDUPE_TTL_MS", 0); ttl > 0 {
+		dedupe = newTaskDedupe(time.Duration(ttl)*time.Millisecond, getEnvInt("TASK_DEDUPE_MAX", 10000))
+	}
+	if raw := getEnv("WORKER_GLOBAL_RATE_LIMIT", ""); raw != "" {
+		rps, err := strconv.ParseFloat(raw, 64)
+		if err != nil || rps <= 0 {
+			log.Fatalf("Invalid WORKER_GLOBAL_RATE_LIMIT %q: want a positive number of executions per second", raw)
+		}
+		globalRateLimit = newTokenBucket(rps, getEnvInt("WORKER_GLOBAL_RATE_BURST", 1))
+	}
+	if path := getEnv("RECORD_FILE", ""); path != "" && *replayPath == "" {
+		var err error
+		if recorder, err = newTaskRecorder(path); err != nil {
+			log.Fatalf("Failed to set up task recording: %v", err)
+		}
+	}
+	if raw := getEnv("TASK_INPUT_DEFAULTS", ""); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &inputDefaults); err != nil {
+			log.Fatalf("Invalid TASK_INPUT_DEFAULTS: %v", err)
+		}
+	}
+	if raw := getEnv("ALLOWED_ENTERPRISES", ""); raw != "" {
+		allowedEnterprises = map[string]bool{}
+		for _, name := range strings.Split(raw, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				allowedEnterprises[name] = true
+			}
+		}
+	}
+	if raw := getEnv("OUTPUT_KEY_MAP", ""); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &outputKeyMap); err != nil {
+			log.Fatalf("Invalid OUTPUT_KEY_MAP: want an object of key renames per task name: %v", err)
+		}
+	}
+	for _, taskName := range strings.Split(getEnv("OUTPUT_COMPRESSION_TASKS", ""), ",") {
+		if taskName = strings.TrimSpace(taskName); taskName != "" {
+			outputCompressionTasks[taskName] = true
+		}
+	}
+	if policy := getEnv("ERROR_POLICY", "default"); statusMappers[policy] != nil {
+		errorPolicy = statusMappers[policy]
+	} else {
+		log.Fatalf("Invalid ERROR_POLICY %q: want default, terminal or retryable", policy)
+	}
 
 	// Conductor Client Setup (conductor-go v1.6.x)
 	apiURL := getEnv("CONDUCTOR_API_URL", "http://localhost:8080/api")
 	authSettings := &settings.AuthenticationSettings{}
 	httpSettings := &settings.HttpSettings{BaseUrl: apiURL}
-	taskRunner := worker.NewTaskRunner(authSettings, httpSettings)
+	apiClient := client.NewAPIClient(authSettings, httpSettings)
+	taskRunner := worker.NewTaskRunnerWithApiClient(apiClient)
+	metadataClient := &client.MetadataResourceApiService{APIClient: apiClient}
+	// Polling starts only once every worker and the admin server are set up
+	sup := newSupervisor(taskRunner, withAutoStart(false), withWorkerTags(workerTags),
+		withAllocSampling(getEnvInt("HANDLER_ALLOC_SAMPLE_EVERY", 0)))
+	sdklog.SetLogger(newSDKLogHook(sdklog.NewStd(nil), sup).
+		WithLogSampling(getEnvInt("SDK_DEBUG_LOG_SAMPLE_EVERY", 1)).
+		WithErrorLogThrottle(time.Duration(getEnvInt("SDK_ERROR_LOG_THROTTLE_MS", 0)) * time.Millisecond))
 
-	// Register Workers
+	var completionHandlers []func(string, *model.TaskResult)
+	if url := getEnv("COMPLETION_WEBHOOK_URL", ""); url != "" {
+		webhookTasks := map[string]bool{}
+		for _, taskName := range strings.Split(getEnv("COMPLETION_WEBHOOK_TASKS", ""), ",") {
+			if taskName = strings.TrimSpace(taskName); taskName != "" {
+				webhookTasks[taskName] = true
+			}
+		}
+		completionHandlers = append(completionHandlers, newCompletionWebhook(url, webhookTasks))
+	}
+	// Upload the artifacts handlers attach to their outputs
+	taskClient := &client.TaskResourceApiService{APIClient: apiClient}
+	switch storage := getEnv("ARTIFACT_STORAGE", ""); storage {
+	case "":
+	case "conductor":
+		artifactUploader = conductorArtifactUploader{taskClient: taskClient}
+	default:
+		log.Fatalf("Invalid ARTIFACT_STORAGE %q: want conductor", storage)
+	}
+	// Report the workflows ended by the listed tasks
+	workflowClient := &client.WorkflowResourceApiService{APIClient: apiClient}
+	workflowEndTasks := map[string]bool{}
+	for _, taskName := range strings.Split(getEnv("WORKFLOW_COMPLETION_TASKS", ""), ",") {
+		if taskName = strings.TrimSpace(taskName); taskName != "" {
+			workflowEndTasks[taskName] = true
+		}
+	}
+	if len(workflowEndTasks) > 0 {
+		completionHandlers = append(completionHandlers, newWorkflowCompletionCheck(workflowClient, workflowEndTasks, logWorkflowDone))
+	}
+	if len(completionHandlers) > 0 {
+		sup.SetCompletionHandler(func(taskName string, result *model.TaskResult) {
+			for _, fn := range completionHandlers {
+				fn(taskName, result)
+			}
+		})
+	}
+
+	if err := sup.SetIdleBackoff(time.Duration(getEnvInt("IDLE_POLL_BACKOFF_MAX_MS", 0))*time.Millisecond, getEnvFloat("IDLE_POLL_BACKOFF_FACTOR", 2)); err != nil {
+		log.Fatalf("Invalid IDLE_POLL_BACKOFF_FACTOR: %v", err)
+	}
+	sup.SetUpdateFailurePause(getEnvInt("UPDATE_FAILURE_PAUSE_THRESHOLD", 0), time.Duration(getEnvInt("UPDATE_FAILURE_PAUSE_COOLDOWN_MS", 30000))*time.Millisecond)
+
+	// Register Workers, taking polling configuration from the Conductor task defs
 	log.Println("Starting Conductor Workers...")
-	taskRunner.StartWorker("create_enterprise_task", withStateLogging(createEnterpriseWorker), 1, 100*time.Millisecond)
-	taskRunner.StartWorker("create_user_task", withStateLogging(onboardEmployeeWorker), 1, 100*time.Millisecond)
+	if err := handlers.Validate(); err != nil {
+		log.Fatalf("Invalid handler registry: %v", err)
+	}
+	if *replayPath != "" {
+		os.Exit(replay(*replayPath, handlers.Handlers(wrapHandler)))
+	}
+
+	// Serve a subset of the tasks, e.g. to run enterprise and user workers
+	// as separate deployments of the same image
+	if enabled := getEnv("ENABLED_TASKS", ""); enabled != "" {
+		var taskNames []string
+		for _, taskName := range strings.Split(enabled, ",") {
+			if taskName = strings.TrimSpace(taskName); taskName != "" {
+				taskNames = append(taskNames, taskName)
+			}
+		}
+		selected, unknown := handlers.Only(taskNames)
+		for _, taskName := range unknown {
+			log.Printf("Warning: ENABLED_TASKS names unknown task %s", taskName)
+		}
+		if len(selected.Names()) == 0 {
+			log.Fatalf("ENABLED_TASKS enables none of the tasks: %s", enabled)
+		}
+		handlers = selected
+		log.Printf("Serving only %s", strings.Join(handlers.Names(), ", "))
+	}
+
+	taskNames := handlers.Names()
+	if *selfTest {
+		os.Exit(runSelfTest(apiClient, taskNames))
+	}
+	domains, err := parseTaskDomains(getEnv("TASK_DOMAINS", ""), taskNames)
+	if err != nil {
+		log.Fatalf("Invalid TASK_DOMAINS: %v", err)
+	}
+
+	maxTasks := getEnvInt("WORKER_MAX_TASKS", 0)
+	var drained []<-chan struct{}
+	if statePath := getEnv("WORKER_STATE_FILE", ""); statePath != "" {
+		// Take over the tasks and configuration of a previous instance
+		data, err := os.ReadFile(statePath)
+		if err != nil {
+			log.Fatalf("Failed to read worker state: %v", err)
+		}
+		imported, err := sup.ImportState(data, handlers.Handlers(wrapHandler))
+		if err != nil {
+			log.Fatalf("Failed to import worker state from %s: %v", statePath, err)
+		}
+		for _, taskName := range imported {
+			loadTaskDef(metadataClient, taskName)
+			if maxTasks > 0 {
+				drained = append(drained, sup.SetMaxTasks(taskName, maxTasks))
+			}
+		}
+	} else {
+		if maxTasks > 0 {
+			for _, taskName := range taskNames {
+				drained = append(drained, sup.SetMaxTasks(taskName, maxTasks))
+			}
+		}
+		if err := handlers.RegisterAll(sup, metadataClient, wrapHandler, domains); err != nil {
+			log.Fatalf("Worker registration failed: %v", err)
+		}
+	}
+
+	// Don't pull tasks while the database the handlers need is unreachable
+	if getEnv("DB_POLL_GATE", "false") == "true" {
+		sup.SetPollGate(func() bool {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			return db.PingContext(ctx) == nil
+		}, pollGateInterval)
+	}
+
+	// Slow polling down while handlers queue up for state writes
+	if stateWriteSlots != nil && getEnv("STATE_WRITE_BACKPRESSURE", "false") == "true" {
+		sup.SetBackpressureGate(func() bool {
+			return len(stateWriteSlots) == cap(stateWriteSlots)
+		}, backpressureInterval)
+	}
+
+	// Admin server for operator endpoints such as task callbacks
+	adminAddr, adminToken := getEnv("ADMIN_ADDR", defaultAdminAddr), getEnv("ADMIN_TOKEN", "")
+	if adminToken == "" && !isLoopbackAddr(adminAddr) {
+		log.Fatalf("ADMIN_ADDR %s accepts remote connections, set ADMIN_TOKEN to protect the admin server", adminAddr)
+	}
+	go func() {
+		log.Printf("Worker admin server running on %s", adminAddr)
+		if err := http.ListenAndServe(adminAddr, newAdminMux(sup, taskClient, workflowClient, adminToken)); err != nil {
+			log.Fatalf("Worker admin server failed: %v", err)
+		}
+	}()
+
+	if err := sup.Start(); err != nil {
+		var regErr *RegisterError
+		if errors.As(err, &regErr) {
+			log.Fatalf("Worker registration failed for task %s: %v", regErr.TaskName, regErr.Err)
+		}
+		log.Fatalf("Worker registration failed: %v", err)
+	}
+
+	// Apply the optional config file, which is re-read on SIGHUP
+	configPath := getEnv("WORKER_CONFIG_FILE", "")
+	if configPath != "" {
+		if err := sup.ReloadConfig(configPath); err != nil {
+			log.Fatalf("Failed to load worker config: %v", err)
+		}
+	}
+	logStartupConfig(apiURL, authSettings, sup)
 
-	// Keep the worker process running
-	select {}
+	// Keep the worker process running until asked to stop, then drain the
+	// enterprise workers before the user workers that depend on them.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2)
+	if maxTasks > 0 {
+		go func() {
+			for _, d := range drained {
+				<-d
+			}
+			log.Printf("Every worker processed its budget of %d task(s)", maxTasks)
+			sigs <- syscall.SIGTERM
+		}()
+	}
+	for sig := range sigs {
+		if sig == syscall.SIGUSR1 {
+			if dump, err := json.Marshal(sup.DumpConfig()); err == nil {
+				log.Printf("Worker config: %s", dump)
+			}
+			continue
+		}
+		if sig == syscall.SIGUSR2 {
+			if sup.TogglePauseAll() {
+				log.Println("Received SIGUSR2, paused polling for every task; send SIGUSR2 again to resume")
+			} else {
+				log.Println("Received SIGUSR2, resumed polling")
+			}
+			continue
+		}
+		if sig == syscall.SIGHUP {
+			if configPath == "" {
+				log.Println("Received SIGHUP but WORKER_CONFIG_FILE is not set, nothing to reload")
+			} else if err := sup.ReloadConfig(configPath); err != nil {
+				log.Printf("Failed to reload worker config: %v", err)
+			}
+			continue
+		}
+		log.Printf("Received %s, shutting down workers...", sig)
+		break
+	}
+	sup.ShutdownInOrder(shutdownTimeout, handlers.ShutdownOrder()...)
+	if recorder != nil {
+		if err := recorder.Close(); err != nil {
+			log.Printf("Failed to flush task recording: %v", err)
+		}
+	}
 }
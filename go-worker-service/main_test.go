@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// errTest is the error failing handlers return in tests.
+var errTest = errors.New("test failure")
+
+func TestWithStateLoggingResults(t *testing.T) {
+	result := &model.TaskResult{Status: model.InProgressTask}
+	tests := []struct {
+		name    string
+		res     interface{}
+		err     error
+		wantRes interface{}
+		wantErr error
+	}{
+		{name: "nil result completes with empty output", wantRes: map[string]interface{}{}},
+		{name: "map output kept", res: map[string]interface{}{"id": 1}, wantRes: map[string]interface{}{"id": 1}},
+		{name: "task result kept", res: result, wantRes: result},
+		{name: "error drops the output", res: map[string]interface{}{"id": 1}, err: errTest, wantErr: errTest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn := withStateLogging(func(*model.Task) (interface{}, error) { return tt.res, tt.err })
+			res, err := fn(&model.Task{TaskId: "t1", TaskDefName: "create_user_task"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(res, tt.wantRes) {
+				t.Errorf("res = %#v, want %#v", res, tt.wantRes)
+			}
+		})
+	}
+}
+
+func TestWithStateLoggingPanic(t *testing.T) {
+	fn := withStateLogging(func(*model.Task) (interface{}, error) { panic("boom") })
+	defer func() {
+		if v := recover(); v != "boom" {
+			t.Errorf("recovered %v, want the handler panic to continue", v)
+		}
+	}()
+	fn(&model.Task{TaskId: "t1"})
+}
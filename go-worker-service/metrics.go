@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+)
+
+// metricsHandler renders the supervisor stats and a few Go runtime metrics in
+// the Prometheus text format. The metric names are stable:
+//
+//	worker_task_last_poll_timestamp_seconds{task,domain}  last successful poll of Conductor
+//	worker_task_last_execution_timestamp_seconds{task,domain}  last task handed to the handler
+//	worker_task_in_flight{task,domain}  handlers currently running
+//	worker_task_batch_size{task,domain}  current batch size
+//	worker_task_success_rate{task,domain}  share of executions completed over the last 5 minutes
+//	worker_state_write_failures_total  failed writes of the worker_state table
+//	worker_state_write_last_failure_timestamp_seconds  last failed worker_state write
+//	go_goroutines  goroutines that currently exist
+//	go_memstats_heap_alloc_bytes  bytes of allocated heap objects
+//	go_memstats_heap_sys_bytes  bytes of heap memory obtained from the OS
+//
+// domain is empty, and so omitted by Prometheus, for the default task domain.
+// Worker tags are added to the per-task metrics as further labels.
+func metricsHandler(sup *supervisor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats := sup.Stats()
+		tasks := make([]string, 0, len(stats))
+		for taskName := range stats {
+			tasks = append(tasks, taskName)
+		}
+		sort.Strings(tasks)
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetricHeader(w, "worker_task_last_poll_timestamp_seconds", "gauge", "Unix time of the last successful poll of Conductor for the task.")
+		for _, taskName := range tasks {
+			if t := stats[taskName].LastPollTime; !t.IsZero() {
+				fmt.Fprintf(w, "worker_task_last_poll_timestamp_seconds{%s} %.3f\n", taskLabels(taskName, stats[taskName]), float64(t.UnixMilli())/1000)
+			}
+		}
+		writeMetricHeader(w, "worker_task_last_execution_timestamp_seconds", "gauge", "Unix time the task handler was last started.")
+		for _, taskName := range tasks {
+			if t := stats[taskName].LastTaskTime; !t.IsZero() {
+				fmt.Fprintf(w, "worker_task_last_execution_timestamp_seconds{%s} %.3f\n", taskLabels(taskName, stats[taskName]), float64(t.UnixMilli())/1000)
+			}
+		}
+		writeMetricHeader(w, "worker_task_in_flight", "gauge", "Task handlers currently running.")
+		for _, taskName := range tasks {
+			fmt.Fprintf(w, "worker_task_in_flight{%s} %d\n", taskLabels(taskName, stats[taskName]), stats[taskName].InFlight)
+		}
+		writeMetricHeader(w, "worker_task_batch_size", "gauge", "Current batch size of the task.")
+		for _, taskName := range tasks {
+			fmt.Fprintf(w, "worker_task_batch_size{%s} %d\n", taskLabels(taskName, stats[taskName]), stats[taskName].BatchSize)
+		}
+
+		writeMetricHeader(w, "worker_task_success_rate", "gauge", "Share of the executions of the last 5 minutes that completed rather than failed.")
+		for _, taskName := range tasks {
+			if rate := stats[taskName].SuccessRate; rate != nil {
+				fmt.Fprintf(w, "worker_task_success_rate{%s} %g\n", taskLabels(taskName, stats[taskName]), *rate)
+			}
+		}
+
+		health := stateHealth.Snapshot()
+		writeMetricHeader(w, "worker_state_write_failures_total", "counter", "Failed writes of the worker_state table.")
+		fmt.Fprintf(w, "worker_state_write_failures_total %d\n", health.FailedWrites)
+		if health.LastErrorTime != nil {
+			writeMetricHeader(w, "worker_state_write_last_failure_timestamp_seconds", "gauge", "Unix time of the last failed worker_state write.")
+			fmt.Fprintf(w, "worker_state_write_last_failure_timestamp_seconds %.3f\n", float64(health.LastErrorTime.UnixMilli())/1000)
+		}
+
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		writeMetricHeader(w, "go_goroutines", "gauge", "Number of goroutines that currently exist.")
+		fmt.Fprintf(w, "go_goroutines %d\n", runtime.NumGoroutine())
+		writeMetricHeader(w, "go_memstats_heap_alloc_bytes", "gauge", "Bytes of allocated heap objects.")
+		fmt.Fprintf(w, "go_memstats_heap_alloc_bytes %d\n", mem.HeapAlloc)
+		writeMetricHeader(w, "go_memstats_heap_sys_bytes", "gauge", "Bytes of heap memory obtained from the OS.")
+		fmt.Fprintf(w, "go_memstats_heap_sys_bytes %d\n", mem.HeapSys)
+	}
+}
+
+// taskLabels renders the labels of a per-task series.
+func taskLabels(taskName string, st TaskStats) string {
+	labels := fmt.Sprintf("task=%q,domain=%q", taskName, st.Domain)
+	names := make([]string, 0, len(st.Tags))
+	for name := range st.Tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		labels += fmt.Sprintf(",%s=%q", name, st.Tags[name])
+	}
+	return labels
+}
+
+func writeMetricHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+	"github.com/conductor-sdk/conductor-go/sdk/worker"
+)
+
+func TestTaskLabels(t *testing.T) {
+	tests := []struct {
+		name string
+		st   TaskStats
+		want string
+	}{
+		{name: "default domain", want: `task="create_user_task",domain=""`},
+		{name: "domain", st: TaskStats{Domain: "blue"}, want: `task="create_user_task",domain="blue"`},
+		{
+			name: "tags sorted after the domain",
+			st:   TaskStats{Domain: "blue", Tags: map[string]string{"team": "identity", "service": "onboarding"}},
+			want: `task="create_user_task",domain="blue",service="onboarding",team="identity"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := taskLabels("create_user_task", tt.st); got != tt.want {
+				t.Errorf("taskLabels() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsDomain(t *testing.T) {
+	noop := func(*model.Task) (interface{}, error) { return nil, nil }
+	tests := []struct {
+		name       string
+		domain     string
+		wantMetric string
+	}{
+		{name: "default domain", wantMetric: `worker_task_in_flight{task="domain_task",domain=""} 0`},
+		{name: "polled domain", domain: "blue", wantMetric: `worker_task_in_flight{task="domain_task",domain="blue"} 0`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sup := newTestSupervisor(t, newFakeConductor(t))
+			if err := sup.RegisterWorker(testWorker("domain_task", noop).With(worker.WithDomain(tt.domain))); err != nil {
+				t.Fatalf("RegisterWorker: %v", err)
+			}
+			sup.recordTask("domain_task")
+			if got := sup.Stats()["domain_task"].Domain; got != tt.domain {
+				t.Errorf("Domain = %q, want %q", got, tt.domain)
+			}
+			rec := httptest.NewRecorder()
+			metricsHandler(sup)(rec, httptest.NewRequest("GET", "/metrics", nil))
+			if !strings.Contains(rec.Body.String(), tt.wantMetric+"\n") {
+				t.Errorf("metrics missing %s:\n%s", tt.wantMetric, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// redactedValue replaces any value that must not reach logs.
+const redactedValue = "***"
+
+// auditRecord is the single line written per task by withAuditLog.
+type auditRecord struct {
+	TaskID     string      `json:"task_id"`
+	WorkflowID string      `json:"workflow_id"`
+	TaskType   string      `json:"task_type"`
+	Input      interface{} `json:"input"`
+	Output     interface{} `json:"output,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	DurationMs int64       `json:"duration_ms"`
+}
+
+// withAuditLog wraps a worker handler to emit one structured log line per task
+// containing the input, the output (or error) and the handler duration.
+// Struct fields tagged `conductor:"secret"` are redacted before logging. The
+// input is a plain map, so its secrets are found through the types the
+// handler bound it to with bindInput.
+func withAuditLog(logger *log.Logger, fn func(*model.Task) (interface{}, error)) func(*model.Task) (interface{}, error) {
+	return func(t *model.Task) (interface{}, error) {
+		cache := &inputCache{byType: map[reflect.Type]reflect.Value{}}
+		if c, loaded := boundInputs.LoadOrStore(t, cache); loaded {
+			cache = c.(*inputCache)
+		} else {
+			defer boundInputs.Delete(t)
+		}
+		start := time.Now()
+		res, err := fn(t)
+		rec := auditRecord{
+			TaskID:     t.TaskId,
+			WorkflowID: t.WorkflowInstanceId,
+			TaskType:   t.TaskType,
+			Input:      cache.redactSecrets(t.InputData),
+			DurationMs: time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			rec.Error = err.Error()
+		} else {
+			rec.Output = redactOutput(res)
+		}
+		line, mErr := json.Marshal(rec)
+		if mErr != nil {
+			logger.Printf("audit: failed to encode record for task %s: %v", t.TaskId, mErr)
+		} else {
+			logger.Printf("audit: %s", line)
+		}
+		return res, err
+	}
+}
+
+// redactSecrets returns a copy of v suitable for logging, with every struct
+// field tagged `conductor:"secret"` replaced by redactedValue. Structs are
+// rendered as maps by walkJSON.
+func redactSecrets(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return walkJSON(reflect.ValueOf(v), func(_ reflect.Value, f *reflect.StructField) (interface{}, bool) {
+		if f != nil && f.Tag.Get("conductor") == "secret" {
+			return redactedValue, true
+		}
+		return nil, false
+	})
+}
+
+// redactSecrets returns a copy of input, as redactSecrets would, with the
+// value of every key bound to a field tagged `conductor:"secret"` by the
+// inputs bound so far replaced by redactedValue.
+func (c *inputCache) redactSecrets(input map[string]interface{}) interface{} {
+	out := redactSecrets(input)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, v := range c.byType {
+		out = maskRedacted(out, redactSecrets(v.Interface()))
+	}
+	return out
+}
+
+// maskRedacted replaces the values of v, as produced by redactSecrets, that
+// are redactedValue at the same place in redacted, a bound input rendered by
+// redactSecrets.
+func maskRedacted(v, redacted interface{}) interface{} {
+	if s, ok := redacted.(string); ok && s == redactedValue {
+		return redactedValue
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		r, ok := redacted.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		for k, val := range v {
+			if rv, ok := r[k]; ok {
+				v[k] = maskRedacted(val, rv)
+			}
+		}
+	case []interface{}:
+		r, ok := redacted.([]interface{})
+		if !ok || len(r) != len(v) {
+			return v
+		}
+		for i, val := range v {
+			v[i] = maskRedacted(val, r[i])
+		}
+	}
+	return v
+}
+
+// redactedOutputKeys holds the task output keys whose values never reach logs
+// or the worker_state table (REDACT_OUTPUT_KEYS).
+var redactedOutputKeys = map[string]bool{}
+
+// parseRedactKeys parses REDACT_OUTPUT_KEYS, a comma-separated list of keys.
+func parseRedactKeys(s string) map[string]bool {
+	keys := map[string]bool{}
+	for _, key := range strings.Split(s, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// redactOutput returns a copy of a task output suitable for logging or
+// storing: secret struct fields are redacted as by redactSecrets, and so is
+// the value of every redactedOutputKeys key, at any depth.
+func redactOutput(v interface{}) interface{} {
+	v = redactSecrets(v)
+	if len(redactedOutputKeys) == 0 {
+		return v
+	}
+	return redactKeys(v, redactedOutputKeys)
+}
+
+// redactResult returns a copy of result whose output is decompressed and
+// redacted by redactOutput, for the copies of results kept or sent anywhere
+// but to Conductor.
+func redactResult(result *model.TaskResult) *model.TaskResult {
+	out := *result
+	output := result.OutputData
+	if output[compressionKey] != nil {
+		if decompressed, err := decompressPayload(output); err == nil {
+			output = decompressed
+		}
+	}
+	if output != nil {
+		out.OutputData, _ = redactOutput(output).(map[string]interface{})
+	}
+	return &out
+}
+
+// redactKeys returns a copy of v, as produced by redactSecrets, with the value
+// of every map key in keys replaced by redactedValue, at any depth.
+func redactKeys(v interface{}, keys map[string]bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			if keys[k] {
+				out[k] = redactedValue
+			} else {
+				out[k] = redactKeys(val, keys)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			out[i] = redactKeys(val, keys)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// withConcurrencyLimit wraps a worker handler so that it only runs while it
+// holds one of slots. Sharing slots across handlers bounds the handler
+// executions running at once across every task name. The SDK still starts a
+// goroutine per polled task; excess ones wait here without running their
+// handler.
+func withConcurrencyLimit(slots *taskSlots, fn func(*model.Task) (interface{}, error)) func(*model.Task) (interface{}, error) {
+	return func(t *model.Task) (interface{}, error) {
+		slots.acquire(t)
+		defer slots.release()
+		return fn(t)
+	}
+}
+
+// executionStarts maps the id of each task that got past the concurrency and
+// rate limits of withExecutionStart to when it did, for the supervisor to
+// measure how long the task waited after being polled.
+var executionStarts sync.Map
+
+// withExecutionStart wraps a worker handler to mark when the task starts
+// running in executionStarts. It goes inside the concurrency and rate limits,
+// so the time spent waiting for them counts as queue wait.
+func withExecutionStart(fn func(*model.Task) (interface{}, error)) func(*model.Task) (interface{}, error) {
+	return func(t *model.Task) (interface{}, error) {
+		executionStarts.Store(t.TaskId, time.Now())
+		return fn(t)
+	}
+}
+
+// withInputDefaults wraps a worker handler so that defaults fill in any keys
+// missing from the task input before the handler runs. Keys present in the
+// input always win; nested maps are merged key by key.
+func withInputDefaults(defaults map[string]interface{}, fn func(*model.Task) (interface{}, error)) func(*model.Task) (interface{}, error) {
+	return func(t *model.Task) (interface{}, error) {
+		if t.InputData == nil {
+			t.InputData = make(map[string]interface{}, len(defaults))
+		}
+		mergeDefaults(t.InputData, defaults)
+		return fn(t)
+	}
+}
+
+// mergeDefaults copies the entries of defaults missing from dst into dst,
+// recursing into maps present in both.
+func mergeDefaults(dst, defaults map[string]interface{}) {
+	for k, def := range defaults {
+		cur, ok := dst[k]
+		if !ok {
+			dst[k] = copyDefault(def)
+			continue
+		}
+		curMap, curIsMap := cur.(map[string]interface{})
+		defMap, defIsMap := def.(map[string]interface{})
+		if curIsMap && defIsMap {
+			mergeDefaults(curMap, defMap)
+		}
+	}
+}
+
+// copyDefault deep-copies maps so that handlers can't modify the shared defaults.
+func copyDefault(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, e := range m {
+		out[k] = copyDefault(e)
+	}
+	return out
+}
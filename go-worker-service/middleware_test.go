@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+func TestWithInputDefaults(t *testing.T) {
+	defaults := map[string]interface{}{
+		"region":   "eu",
+		"settings": map[string]interface{}{"locale": "en", "tz": "UTC"},
+	}
+	tests := []struct {
+		name  string
+		input map[string]interface{}
+		want  map[string]interface{}
+	}{
+		{
+			name: "nil input takes every default",
+			want: map[string]interface{}{"region": "eu", "settings": map[string]interface{}{"locale": "en", "tz": "UTC"}},
+		},
+		{
+			name:  "present keys win",
+			input: map[string]interface{}{"region": "us", "user": "ada"},
+			want:  map[string]interface{}{"region": "us", "user": "ada", "settings": map[string]interface{}{"locale": "en", "tz": "UTC"}},
+		},
+		{
+			name:  "nested maps merged key by key",
+			input: map[string]interface{}{"settings": map[string]interface{}{"locale": "fr"}},
+			want:  map[string]interface{}{"region": "eu", "settings": map[string]interface{}{"locale": "fr", "tz": "UTC"}},
+		},
+		{
+			name:  "explicit null kept",
+			input: map[string]interface{}{"region": nil},
+			want:  map[string]interface{}{"region": nil, "settings": map[string]interface{}{"locale": "en", "tz": "UTC"}},
+		},
+		{
+			name:  "non-map value not merged into",
+			input: map[string]interface{}{"settings": "none"},
+			want:  map[string]interface{}{"region": "eu", "settings": "none"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]interface{}
+			fn := withInputDefaults(defaults, func(task *model.Task) (interface{}, error) {
+				got = task.InputData
+				// Handlers changing their input must not change the defaults
+				if s, ok := task.InputData["settings"].(map[string]interface{}); ok {
+					s["mutated"] = true
+				}
+				return nil, nil
+			})
+			fn(&model.Task{InputData: tt.input})
+			if s, ok := got["settings"].(map[string]interface{}); ok {
+				delete(s, "mutated")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("input = %v, want %v", got, tt.want)
+			}
+			if _, ok := defaults["settings"].(map[string]interface{})["mutated"]; ok {
+				t.Error("handler changed the shared defaults")
+			}
+		})
+	}
+}
+
+func TestWithAuditLog(t *testing.T) {
+	type credentials struct {
+		User     string `json:"user"`
+		Password string `json:"password" conductor:"secret"`
+	}
+	type loginInput struct {
+		APIKey      string        `json:"api_key" conductor:"secret"`
+		Credentials credentials   `json:"credentials"`
+		Tokens      []credentials `json:"tokens"`
+	}
+	type tokenOutput struct {
+		Token  string `json:"token" conductor:"secret"`
+		UserID int    `json:"user_id"`
+	}
+	bindLogin := func(t *model.Task) (interface{}, error) {
+		var in loginInput
+		return nil, bindInput(t, &in)
+	}
+	input := map[string]interface{}{
+		"api_key":     "k",
+		"region":      "eu",
+		"credentials": map[string]interface{}{"user": "ada", "password": "p"},
+		"tokens":      []interface{}{map[string]interface{}{"user": "ada", "password": "x"}},
+	}
+	redacted := map[string]interface{}{
+		"api_key":     "***",
+		"region":      "eu",
+		"credentials": map[string]interface{}{"user": "ada", "password": "***"},
+		"tokens":      []interface{}{map[string]interface{}{"user": "ada", "password": "***"}},
+	}
+	tests := []struct {
+		name       string
+		handler    model.ExecuteTaskFunction
+		wantInput  interface{}
+		wantOutput interface{}
+		wantError  string
+	}{
+		{name: "unbound input logged as is", handler: func(*model.Task) (interface{}, error) { return nil, nil }, wantInput: input},
+		{name: "secrets of the bound type redacted", handler: bindLogin, wantInput: redacted},
+		{
+			name: "secrets bound by an input middleware redacted",
+			handler: withInputMiddleware(func(*model.Task) (interface{}, error) { return nil, nil },
+				func(t *model.Task, decode func(dst interface{}) error, next func() (interface{}, error)) (interface{}, error) {
+					var in loginInput
+					if err := decode(&in); err != nil {
+						return nil, err
+					}
+					return next()
+				}),
+			wantInput: redacted,
+		},
+		{
+			name:       "secret output field redacted",
+			handler:    func(*model.Task) (interface{}, error) { return tokenOutput{Token: "t", UserID: 1}, nil },
+			wantInput:  input,
+			wantOutput: map[string]interface{}{"token": "***", "user_id": float64(1)},
+		},
+		{name: "error logged without output", handler: func(*model.Task) (interface{}, error) { return "ignored", errTest }, wantInput: input, wantError: "test failure"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			task := &model.Task{TaskId: "t1", TaskType: "login_task", InputData: input}
+			withAuditLog(log.New(&buf, "", 0), tt.handler)(task)
+			if _, ok := boundInputs.Load(task); ok {
+				t.Error("bound inputs left behind")
+			}
+
+			var rec struct {
+				TaskID string      `json:"task_id"`
+				Input  interface{} `json:"input"`
+				Output interface{} `json:"output"`
+				Error  string      `json:"error"`
+			}
+			line, ok := strings.CutPrefix(strings.TrimSpace(buf.String()), "audit: ")
+			if !ok {
+				t.Fatalf("logged %q, want an audit line", buf.String())
+			}
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				t.Fatalf("decode audit line %s: %v", line, err)
+			}
+			if rec.TaskID != "t1" || rec.Error != tt.wantError {
+				t.Errorf("logged task %s with error %q, want t1 with %q", rec.TaskID, rec.Error, tt.wantError)
+			}
+			if !reflect.DeepEqual(rec.Input, tt.wantInput) {
+				t.Errorf("logged input %v, want %v", rec.Input, tt.wantInput)
+			}
+			if !reflect.DeepEqual(rec.Output, tt.wantOutput) {
+				t.Errorf("logged output %v, want %v", rec.Output, tt.wantOutput)
+			}
+			if task.InputData["api_key"] != "k" {
+				t.Errorf("task input api_key = %v, want it left untouched", task.InputData["api_key"])
+			}
+		})
+	}
+}
+
+func TestRedactOutputKeys(t *testing.T) {
+	saved := redactedOutputKeys
+	t.Cleanup(func() { redactedOutputKeys = saved })
+	redactedOutputKeys = parseRedactKeys(" email, ,token ")
+	if want := map[string]bool{"email": true, "token": true}; !reflect.DeepEqual(redactedOutputKeys, want) {
+		t.Fatalf("parseRedactKeys() = %v, want %v", redactedOutputKeys, want)
+	}
+	tests := []struct {
+		name   string
+		output map[string]interface{}
+		want   map[string]interface{}
+	}{
+		{
+			name:   "top-level key",
+			output: map[string]interface{}{"token": "t", "user_id": 1},
+			want:   map[string]interface{}{"token": "***", "user_id": float64(1)},
+		},
+		{
+			name:   "nested map",
+			output: map[string]interface{}{"user": map[string]interface{}{"email": "ada@example.com", "name": "ada"}},
+			want:   map[string]interface{}{"user": map[string]interface{}{"email": "***", "name": "ada"}},
+		},
+		{
+			name: "slice of maps",
+			output: map[string]interface{}{"users": []interface{}{
+				map[string]interface{}{"email": "ada@example.com"},
+				map[string]interface{}{"name": "grace"},
+			}},
+			want: map[string]interface{}{"users": []interface{}{
+				map[string]interface{}{"email": "***"},
+				map[string]interface{}{"name": "grace"},
+			}},
+		},
+		{
+			name:   "missing key",
+			output: map[string]interface{}{"user_id": 1, "emails": []interface{}{"ada@example.com"}},
+			want:   map[string]interface{}{"user_id": float64(1), "emails": []interface{}{"ada@example.com"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			task := &model.Task{TaskId: "t1"}
+			withAuditLog(log.New(&buf, "", 0), func(*model.Task) (interface{}, error) { return tt.output, nil })(task)
+			var rec struct {
+				Output map[string]interface{} `json:"output"`
+			}
+			line, _ := strings.CutPrefix(strings.TrimSpace(buf.String()), "audit: ")
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				t.Fatalf("decode audit line %s: %v", line, err)
+			}
+			if !reflect.DeepEqual(rec.Output, tt.want) {
+				t.Errorf("logged output %v, want %v", rec.Output, tt.want)
+			}
+
+			var stored map[string]interface{}
+			if err := json.Unmarshal([]byte(*workerStateOutput(tt.output)), &stored); err != nil {
+				t.Fatalf("decode worker state output: %v", err)
+			}
+			if !reflect.DeepEqual(stored, tt.want) {
+				t.Errorf("worker state output %v, want %v", stored, tt.want)
+			}
+			if tt.output["token"] == "***" {
+				t.Error("handler output redacted in place")
+			}
+		})
+	}
+	if got := workerStateOutput(nil); got != nil {
+		t.Errorf("workerStateOutput(nil) = %q, want nil", *got)
+	}
+}
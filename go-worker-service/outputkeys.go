@@ -0,0 +1,47 @@
+package main
+
+import (
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// withOutputKeyMap wraps a worker handler to rename the top-level keys of its
+// output found in the renames of its task name in keysByTask, e.g.
+// {"create_enterprise_task": {"enterprise_id": "entpId"}} for a workflow
+// expecting camelCase. Unmapped keys, and the outputs of other tasks, pass
+// through. Outputs that aren't maps are converted with their JSON field names
+// first.
+func withOutputKeyMap(keysByTask map[string]map[string]string, fn func(*model.Task) (interface{}, error)) func(*model.Task) (interface{}, error) {
+	return func(t *model.Task) (interface{}, error) {
+		res, err := fn(t)
+		keys := keysByTask[t.TaskDefName]
+		if err != nil || res == nil || len(keys) == 0 {
+			return res, err
+		}
+		if tr, ok := res.(*model.TaskResult); ok {
+			tr.OutputData = renameKeys(tr.OutputData, keys)
+			return tr, nil
+		}
+		out, ok := res.(map[string]interface{})
+		if !ok {
+			if out, err = model.ConvertToMap(res); err != nil {
+				return nil, err
+			}
+		}
+		return renameKeys(out, keys), nil
+	}
+}
+
+// renameKeys returns a copy of m with the keys found in keys renamed.
+func renameKeys(m map[string]interface{}, keys map[string]string) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if renamed, ok := keys[k]; ok {
+			k = renamed
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+func TestWithOutputKeyMap(t *testing.T) {
+	keys := map[string]map[string]string{
+		"create_enterprise_task": {"enterprise_id": "entpId"},
+	}
+	tests := []struct {
+		name     string
+		taskName string
+		out      interface{}
+		want     interface{}
+	}{
+		{
+			name:     "mapped task renamed",
+			taskName: "create_enterprise_task",
+			out:      map[string]interface{}{"enterprise_id": 1, "name": "acme"},
+			want:     map[string]interface{}{"entpId": 1, "name": "acme"},
+		},
+		{
+			name:     "other task unchanged",
+			taskName: "create_user_task",
+			out:      map[string]interface{}{"enterprise_id": 1, "user_id": 2},
+			want:     map[string]interface{}{"enterprise_id": 1, "user_id": 2},
+		},
+		{
+			name:     "struct output converted",
+			taskName: "create_enterprise_task",
+			out: struct {
+				EnterpriseID int `json:"enterprise_id"`
+			}{7},
+			want: map[string]interface{}{"entpId": float64(7)},
+		},
+		{
+			name:     "task result output renamed",
+			taskName: "create_enterprise_task",
+			out:      &model.TaskResult{OutputData: map[string]interface{}{"enterprise_id": 1}},
+			want:     &model.TaskResult{OutputData: map[string]interface{}{"entpId": 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn := withOutputKeyMap(keys, func(*model.Task) (interface{}, error) { return tt.out, nil })
+			got, err := fn(&model.Task{TaskDefName: tt.taskName})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("output = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithOutputCompressionTasks(t *testing.T) {
+	out := map[string]interface{}{"report": "a long enough output to pass the threshold"}
+	tests := []struct {
+		name      string
+		taskNames map[string]bool
+		taskName  string
+		want      bool
+	}{
+		{name: "every task by default", taskName: "create_user_task", want: true},
+		{name: "listed task", taskNames: map[string]bool{"enrich_user_task": true}, taskName: "enrich_user_task", want: true},
+		{name: "unlisted task", taskNames: map[string]bool{"enrich_user_task": true}, taskName: "create_user_task", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn := withOutputCompression(8, tt.taskNames, func(*model.Task) (interface{}, error) { return out, nil })
+			got, err := fn(&model.Task{TaskDefName: tt.taskName})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			m, _ := got.(map[string]interface{})
+			if compressed := m[compressionKey] != nil; compressed != tt.want {
+				t.Fatalf("compressed = %v, want %v", compressed, tt.want)
+			}
+			if !tt.want {
+				return
+			}
+			decompressed, err := decompressPayload(m)
+			if err != nil {
+				t.Fatalf("decompress: %v", err)
+			}
+			if !reflect.DeepEqual(decompressed, out) {
+				t.Errorf("decompressed = %v, want %v", decompressed, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"log"
+	"sort"
+)
+
+// Reasons for which polling of a task can be paused.
+const (
+	pauseReasonOperator       = "operator"
+	pauseReasonMaxInFlight    = "max_in_flight"
+	pauseReasonHandoff        = "handoff"
+	pauseReasonPollGate       = "poll_gate"
+	pauseReasonUpdateFailures = "update_failures"
+	pauseReasonAll            = "all"
+)
+
+// Pause stops polling for taskName until Resume is called. Tasks already
+// polled still run to completion.
+func (s *supervisor) Pause(taskName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pauseLocked(taskName, pauseReasonOperator)
+}
+
+// Resume restarts polling for a task stopped by Pause. Polling stays paused
+// while the task has other pause reasons, such as its in-flight cap.
+func (s *supervisor) Resume(taskName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resumeLocked(taskName, pauseReasonOperator)
+}
+
+// PauseAll stops polling for every task, including tasks registered later,
+// until ResumeAll is called. Tasks already polled still run to completion.
+func (s *supervisor) PauseAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pausedAll = true
+	for taskName := range s.workers {
+		s.pauseLocked(taskName, pauseReasonAll)
+	}
+}
+
+// ResumeAll restarts polling stopped by PauseAll. Tasks stay paused while they
+// have other pause reasons, such as a Pause.
+func (s *supervisor) ResumeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pausedAll = false
+	for taskName := range s.workers {
+		s.resumeLocked(taskName, pauseReasonAll)
+	}
+}
+
+// TogglePauseAll calls PauseAll, or ResumeAll if every task is already paused
+// by it, and reports whether polling is now paused.
+func (s *supervisor) TogglePauseAll() bool {
+	s.mu.Lock()
+	paused := s.pausedAll
+	s.mu.Unlock()
+	if paused {
+		s.ResumeAll()
+	} else {
+		s.PauseAll()
+	}
+	return !paused
+}
+
+// PauseWithCancel pauses taskName like Pause and cancels the context of each of
+// its running handlers, e.g. before a database migration. It returns the
+// number of handlers cancelled. Cancellation is cooperative: handlers that
+// ignore their task context run to completion.
+func (s *supervisor) PauseWithCancel(taskName string) int {
+	s.Pause(taskName)
+	n := cancelTasksOf(taskName)
+	log.Printf("Supervisor: cancelled %d running task(s) of %s", n, taskName)
+	return n
+}
+
+// setMaxInFlightLocked caps the tasks of taskName polled but not yet updated,
+// from the start of their handler until the SDK delivers their result.
+// Polling pauses when the cap is reached and resumes once a result update is
+// done. Tasks the SDK already polled still run, so the cap may be exceeded by
+// up to one batch. Zero removes the cap. The caller must hold s.mu.
+func (s *supervisor) setMaxInFlightLocked(taskName string, n int) {
+	if n <= 0 {
+		delete(s.maxInFlight, taskName)
+		s.resumeLocked(taskName, pauseReasonMaxInFlight)
+		return
+	}
+	s.maxInFlight[taskName] = n
+	if s.inFlight[taskName] >= n {
+		s.pauseLocked(taskName, pauseReasonMaxInFlight)
+	} else {
+		s.resumeLocked(taskName, pauseReasonMaxInFlight)
+	}
+}
+
+// pauseReasonsOf returns why taskName is paused, if it is.
+func (s *supervisor) pauseReasonsOf(taskName string) []string {
+	var reasons []string
+	for reason := range s.pauseReasons[taskName] {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// pauseLocked adds reason to the pause reasons of taskName, pausing the runner
+// on the first one. The caller must hold s.mu.
+func (s *supervisor) pauseLocked(taskName, reason string) {
+	if s.pauseReasons[taskName][reason] {
+		return
+	}
+	if s.pauseReasons[taskName] == nil {
+		s.pauseReasons[taskName] = make(map[string]bool)
+	}
+	s.pauseReasons[taskName][reason] = true
+	if len(s.pauseReasons[taskName]) == 1 {
+		s.runner.Pause(taskName)
+		log.Printf("Supervisor: paused polling for %s (%s)", taskName, reason)
+	}
+}
+
+// resumeLocked removes reason from the pause reasons of taskName, resuming the
+// runner once none is left. The caller must hold s.mu.
+func (s *supervisor) resumeLocked(taskName, reason string) {
+	if !s.pauseReasons[taskName][reason] {
+		return
+	}
+	delete(s.pauseReasons[taskName], reason)
+	if len(s.pauseReasons[taskName]) == 0 {
+		delete(s.pauseReasons, taskName)
+		s.runner.Resume(taskName)
+		log.Printf("Supervisor: resumed polling for %s", taskName)
+	}
+}
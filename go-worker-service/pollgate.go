@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// pollGateInterval is how often a poll gate is consulted, matching the SDK's
+// back-off after a generic polling error.
+const pollGateInterval = 200 * time.Millisecond
+
+// SetPollGate consults gate every interval and pauses polling for every task
+// while it returns false, e.g. while a downstream the handlers need is down,
+// so the worker doesn't pull tasks only to fail them. Polling resumes once
+// the gate returns true again, unless the task has other pause reasons.
+func (s *supervisor) SetPollGate(gate func() bool, interval time.Duration) {
+	go func() {
+		open := true
+		for range time.Tick(interval) {
+			ok := gate()
+			if ok != open {
+				if ok {
+					log.Println("Supervisor: poll gate open, resuming polling")
+				} else {
+					log.Println("Supervisor: poll gate closed, pausing polling")
+				}
+				open = ok
+			}
+			s.mu.Lock()
+			for taskName := range s.workers {
+				if open {
+					s.resumeLocked(taskName, pauseReasonPollGate)
+				} else {
+					s.pauseLocked(taskName, pauseReasonPollGate)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}()
+}
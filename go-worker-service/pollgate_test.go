@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+func TestSetPollGate(t *testing.T) {
+	noop := func(*model.Task) (interface{}, error) { return nil, nil }
+	tests := []struct {
+		name          string
+		gate          []bool
+		operatorPause bool
+		wantReasons   []string
+	}{
+		{name: "open", gate: []bool{true}},
+		{name: "closed", gate: []bool{false}, wantReasons: []string{pauseReasonPollGate}},
+		{name: "reopened", gate: []bool{false, true}},
+		{name: "closed with an operator pause", gate: []bool{false}, operatorPause: true, wantReasons: []string{pauseReasonOperator, pauseReasonPollGate}},
+		{name: "reopened keeps an operator pause", gate: []bool{false, true}, operatorPause: true, wantReasons: []string{pauseReasonOperator}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeConductor(t)
+			sup := newTestSupervisor(t, f)
+			if err := sup.RegisterWorker(testWorker("gated_task", noop)); err != nil {
+				t.Fatalf("RegisterWorker: %v", err)
+			}
+			if tt.operatorPause {
+				sup.Pause("gated_task")
+			}
+			var open atomic.Bool
+			open.Store(true)
+			sup.SetPollGate(open.Load, 5*time.Millisecond)
+			for _, state := range tt.gate {
+				open.Store(state)
+				time.Sleep(50 * time.Millisecond)
+			}
+
+			sup.mu.Lock()
+			reasons := sup.pauseReasonsOf("gated_task")
+			sup.mu.Unlock()
+			if !reflect.DeepEqual(reasons, tt.wantReasons) {
+				t.Errorf("pause reasons = %v, want %v", reasons, tt.wantReasons)
+			}
+
+			f.enqueue("gated_task", model.Task{TaskId: "t1", TaskDefName: "gated_task", TaskType: "gated_task"})
+			if len(tt.wantReasons) == 0 {
+				f.waitUpdates(t, 1)
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			if len(f.updates) != 0 {
+				t.Errorf("got %d task update(s) while paused, want 0", len(f.updates))
+			}
+		})
+	}
+}
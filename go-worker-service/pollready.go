@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// polledLogMessage is logged by the TaskRunner after a poll that returned
+// tasks. Polls that come back empty log nothing, so their success is inferred
+// from the next poll of the task starting without a poll error in between.
+const polledLogMessage = "Polled tasks"
+
+// pollFailedPrefix starts the error of the pollErrorLogMessage lines logged
+// for a failed poll, as opposed to a paused or misconfigured task.
+const pollFailedPrefix = "failed to poll"
+
+// pollStarted records that a poll of taskName is about to be sent. A poll of
+// the task still outstanding from before has succeeded without returning
+// work, since a failed one would have been reported through pollFailed and
+// one returning tasks through pollReturned.
+func (s *supervisor) pollStarted(taskName string) {
+	if s.pollPending(taskName).Swap(true) {
+		s.pollSucceeded(taskName)
+		s.pollEmpty(taskName)
+	}
+}
+
+// pollReturned records that the outstanding poll of taskName returned count tasks.
+func (s *supervisor) pollReturned(taskName string, count int) {
+	s.pollSucceeded(taskName)
+	if count == 0 {
+		return
+	}
+	s.pollPending(taskName).Store(false)
+	s.pollReturnedTasks(taskName)
+}
+
+// pollPending returns the flag set while a poll of taskName awaits its
+// outcome.
+func (s *supervisor) pollPending(taskName string) *atomic.Bool {
+	v, ok := s.pollsPending.Load(taskName)
+	if !ok {
+		v, _ = s.pollsPending.LoadOrStore(taskName, new(atomic.Bool))
+	}
+	return v.(*atomic.Bool)
+}
+
+// pollSucceeded records a successful poll of Conductor for taskName.
+func (s *supervisor) pollSucceeded(taskName string) {
+	s.polled.Store(true)
+	s.recordPoll(taskName)
+}
+
+// pollFailed records that the outstanding poll of taskName failed with err,
+// unless err comes from some other step of the poll loop.
+func (s *supervisor) pollFailed(taskName string, err interface{}) {
+	if !strings.HasPrefix(fmt.Sprint(err), pollFailedPrefix) {
+		return
+	}
+	if v, ok := s.pollsPending.Load(taskName); ok {
+		v.(*atomic.Bool).Store(false)
+	}
+}
+
+// IsReady reports whether a poll of Conductor has succeeded since the first
+// worker was registered, proving the worker can reach and authenticate with
+// it. It turns false again once every worker has been shut down.
+func (s *supervisor) IsReady() bool {
+	return s.polled.Load()
+}
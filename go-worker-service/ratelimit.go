@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// tokenBucket limits a rate of events to rate per second with bursts of up to
+// burst events.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// reserve takes a token and returns how long to wait before it may be used.
+// When maxWait > 0 and the wait would be longer, no token is taken and ok is
+// false.
+func (b *tokenBucket) reserve(maxWait time.Duration) (wait time.Duration, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		wait = time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	}
+	if maxWait > 0 && wait > maxWait {
+		return wait, false
+	}
+	b.tokens--
+	return wait, true
+}
+
+// withRateLimit wraps a worker handler so that every execution takes a token
+// from bucket first. Sharing bucket across handlers bounds the rate of
+// executions across every task name, e.g. to protect a shared downstream;
+// unlike withConcurrencyLimit it doesn't bound how many run at once. Tasks
+// wait for their token for up to their handler timeout; a task that would
+// wait longer fails with a retryable error without running its handler.
+func withRateLimit(bucket *tokenBucket, fn func(*model.Task) (interface{}, error)) func(*model.Task) (interface{}, error) {
+	return func(t *model.Task) (interface{}, error) {
+		maxWait := handlerTimeout(t.TaskDefName)
+		wait, ok := bucket.reserve(maxWait)
+		if !ok {
+			return nil, fmt.Errorf("global rate limit: next execution slot in %s exceeds the %s handler timeout", wait.Round(time.Millisecond), maxWait)
+		}
+		time.Sleep(wait)
+		return fn(t)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+func TestTokenBucketReserve(t *testing.T) {
+	// Waits are compared within slack of their expected value, as time passes
+	// between reservations
+	const slack = 20 * time.Millisecond
+	tests := []struct {
+		name    string
+		rate    float64
+		burst   int
+		idle    time.Duration
+		maxWait time.Duration
+		want    []time.Duration
+		wantOK  []bool
+	}{
+		{
+			name:   "burst then one token per 1/rate",
+			rate:   10,
+			burst:  3,
+			want:   []time.Duration{0, 0, 0, 100 * time.Millisecond, 200 * time.Millisecond},
+			wantOK: []bool{true, true, true, true, true},
+		},
+		{
+			name:   "burst below one allows one",
+			rate:   20,
+			burst:  0,
+			want:   []time.Duration{0, 50 * time.Millisecond, 100 * time.Millisecond},
+			wantOK: []bool{true, true, true},
+		},
+		{
+			name:   "idle refill capped at the burst",
+			rate:   10,
+			burst:  2,
+			idle:   time.Hour,
+			want:   []time.Duration{0, 0, 100 * time.Millisecond},
+			wantOK: []bool{true, true, true},
+		},
+		{
+			name:    "wait beyond maxWait takes no token",
+			rate:    10,
+			burst:   1,
+			maxWait: 150 * time.Millisecond,
+			want:    []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond, 200 * time.Millisecond},
+			wantOK:  []bool{true, true, false, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTokenBucket(tt.rate, tt.burst)
+			b.last = b.last.Add(-tt.idle)
+			for i, want := range tt.want {
+				wait, ok := b.reserve(tt.maxWait)
+				if ok != tt.wantOK[i] || wait > want || wait < want-slack {
+					t.Errorf("reserve #%d = %s, %v, want %s, %v", i+1, wait, ok, want, tt.wantOK[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWithRateLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		calls    int
+		wantRuns int
+		wantErr  bool
+	}{
+		{name: "within the handler timeout", timeout: time.Second, calls: 2, wantRuns: 2},
+		{name: "no handler timeout waits", calls: 2, wantRuns: 2},
+		{name: "beyond the handler timeout fails", timeout: 10 * time.Millisecond, calls: 2, wantRuns: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taskName := "rate_limited_" + strings.ReplaceAll(tt.name, " ", "_")
+			if tt.timeout > 0 {
+				handlerTimeouts.Store(taskName, tt.timeout)
+				t.Cleanup(func() { handlerTimeouts.Delete(taskName) })
+			}
+			runs := 0
+			fn := withRateLimit(newTokenBucket(20, 1), func(*model.Task) (interface{}, error) {
+				runs++
+				return nil, nil
+			})
+			var err error
+			for i := 0; i < tt.calls; i++ {
+				if _, callErr := fn(&model.Task{TaskDefName: taskName}); callErr != nil {
+					err = callErr
+				}
+			}
+			if runs != tt.wantRuns {
+				t.Errorf("handler ran %d time(s), want %d", runs, tt.wantRuns)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want an error: %v", err, tt.wantErr)
+			}
+			if _, terminal := err.(*model.NonRetryableError); terminal {
+				t.Errorf("err = %v, want a retryable error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// taskRecord is one line of a record file: a polled task and the result sent
+// back for it.
+type taskRecord struct {
+	Task   *model.Task       `json:"task"`
+	Result *model.TaskResult `json:"result"`
+}
+
+// taskRecorder appends taskRecords as JSON lines to a file. It is buffered and
+// safe for concurrent handlers; Close flushes it.
+type taskRecorder struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+}
+
+func newTaskRecorder(path string) (*taskRecorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open record file: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	return &taskRecorder{file: f, w: w, enc: json.NewEncoder(w)}, nil
+}
+
+func (r *taskRecorder) record(rec taskRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(rec); err != nil {
+		log.Printf("failed to record task %s: %v", rec.Task.TaskId, err)
+	}
+}
+
+// Close flushes buffered records and closes the file.
+func (r *taskRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.w.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+// withRecording wraps a worker handler to record every task together with the
+// result sent to Conductor, its output decompressed and redacted as by
+// redactResult. It must wrap every other middleware so the recorded result is
+// the one actually sent.
+func withRecording(rec *taskRecorder, fn func(*model.Task) (interface{}, error)) func(*model.Task) (interface{}, error) {
+	return func(t *model.Task) (interface{}, error) {
+		res, err := fn(t)
+		result := resultOf(t, res, err)
+		rec.record(taskRecord{Task: t, Result: redactResult(result)})
+		return result, err
+	}
+}
+
+// resultOf returns the task result the runner sends for a handler outcome.
+func resultOf(t *model.Task, res interface{}, err error) *model.TaskResult {
+	if err != nil && res == nil {
+		return model.NewTaskResultFromTaskWithError(t, err)
+	}
+	result, cErr := model.GetTaskResultFromTaskExecutionOutput(t, res)
+	if cErr != nil {
+		return model.NewTaskResultFromTaskWithError(t, cErr)
+	}
+	return result
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+func TestWithRecording(t *testing.T) {
+	tests := []struct {
+		name       string
+		res        interface{}
+		err        error
+		wantStatus model.TaskResultStatus
+		wantReason string
+		wantOutput map[string]interface{}
+	}{
+		{name: "output", res: map[string]interface{}{"user_id": 1}, wantStatus: model.CompletedTask, wantOutput: map[string]interface{}{"user_id": float64(1)}},
+		{name: "failure", err: errTest, wantStatus: model.FailedTask, wantReason: "test failure"},
+		{
+			name:       "task result",
+			res:        &model.TaskResult{Status: model.InProgressTask, OutputData: map[string]interface{}{"step": "sent"}},
+			wantStatus: model.InProgressTask,
+			wantOutput: map[string]interface{}{"step": "sent"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tasks.jsonl")
+			rec, err := newTaskRecorder(path)
+			if err != nil {
+				t.Fatalf("newTaskRecorder: %v", err)
+			}
+			task := &model.Task{TaskId: "t1", WorkflowInstanceId: "wf1", TaskDefName: "create_user_task"}
+			sent, _ := withRecording(rec, func(*model.Task) (interface{}, error) { return tt.res, tt.err })(task)
+			if err := rec.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatalf("open record file: %v", err)
+			}
+			defer f.Close()
+			var records []taskRecord
+			for sc := bufio.NewScanner(f); sc.Scan(); {
+				var r taskRecord
+				if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+					t.Fatalf("decode record: %v", err)
+				}
+				records = append(records, r)
+			}
+			if len(records) != 1 {
+				t.Fatalf("got %d record(s), want 1", len(records))
+			}
+			got := records[0]
+			if got.Task.TaskId != "t1" || got.Result.Status != tt.wantStatus || got.Result.ReasonForIncompletion != tt.wantReason {
+				t.Errorf("recorded task %s with %s (%q), want t1 with %s (%q)", got.Task.TaskId, got.Result.Status, got.Result.ReasonForIncompletion, tt.wantStatus, tt.wantReason)
+			}
+			for k, want := range tt.wantOutput {
+				if got.Result.OutputData[k] != want {
+					t.Errorf("recorded %s = %v, want %v", k, got.Result.OutputData[k], want)
+				}
+			}
+			if result, ok := sent.(*model.TaskResult); !ok || result.Status != tt.wantStatus {
+				t.Errorf("sent %#v, want a %s task result", sent, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	redactedOutputKeys["email"] = true
+	t.Cleanup(func() { delete(redactedOutputKeys, "email") })
+
+	recorded := func(*model.Task) (interface{}, error) {
+		return map[string]interface{}{"user_id": 1, "email": "ada@example.com"}, nil
+	}
+	tests := []struct {
+		name      string
+		replayed  model.ExecuteTaskFunction
+		wantDiffs []string
+	}{
+		{name: "same output", replayed: recorded},
+		{
+			name: "redacted key differs only in its value",
+			replayed: func(*model.Task) (interface{}, error) {
+				return map[string]interface{}{"user_id": 1, "email": "grace@example.com"}, nil
+			},
+		},
+		{
+			name: "changed output key",
+			replayed: func(*model.Task) (interface{}, error) {
+				return map[string]interface{}{"user_id": 2, "email": "ada@example.com"}, nil
+			},
+			wantDiffs: []string{"user_id: recorded 1, replayed 2"},
+		},
+		{
+			name:     "failure",
+			replayed: func(*model.Task) (interface{}, error) { return nil, errTest },
+			wantDiffs: []string{
+				"status: recorded COMPLETED, replayed FAILED",
+				`reason: recorded "", replayed "test failure"`,
+				"email: recorded ***, replayed <nil>",
+				"user_id: recorded 1, replayed <nil>",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tasks.jsonl")
+			rec, err := newTaskRecorder(path)
+			if err != nil {
+				t.Fatalf("newTaskRecorder: %v", err)
+			}
+			task := &model.Task{TaskId: "t1", WorkflowInstanceId: "wf1", TaskDefName: "create_user_task"}
+			sent, _ := withRecording(rec, recorded)(task)
+			if out := sent.(*model.TaskResult).OutputData; out["email"] != "ada@example.com" {
+				t.Errorf("sent email %v, want the unredacted value", out["email"])
+			}
+			if err := rec.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			results, err := ReplayFile(path, tt.replayed)
+			if err != nil {
+				t.Fatalf("ReplayFile: %v", err)
+			}
+			if len(results) != 1 {
+				t.Fatalf("got %d replay result(s), want 1", len(results))
+			}
+			if got := results[0]; got.TaskID != "t1" || got.Error != "" || !reflect.DeepEqual(got.Diffs, tt.wantDiffs) {
+				t.Errorf("replay = %+v, want diffs %q", got, tt.wantDiffs)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/conductor-sdk/conductor-go/sdk/client"
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+	"github.com/conductor-sdk/conductor-go/sdk/worker"
+)
+
+// HandlerRegistry maps task names to the handlers serving them. Handlers add
+// themselves to handlers from init functions, so adding one doesn't take
+// editing main.
+type HandlerRegistry struct {
+	handlers   map[string]model.ExecuteTaskFunction
+	duplicates []string
+	// after maps task names to the tasks whose output they consume; see After.
+	after map[string][]string
+}
+
+// handlers is the registry of every handler built into the worker.
+var handlers = newHandlerRegistry()
+
+func newHandlerRegistry() *HandlerRegistry {
+	return &HandlerRegistry{handlers: map[string]model.ExecuteTaskFunction{}, after: map[string][]string{}}
+}
+
+// Register adds the handler of taskName. Registering a task name twice keeps
+// the first handler and is reported by Validate, as init functions have no way
+// to fail.
+func (r *HandlerRegistry) Register(taskName string, fn model.ExecuteTaskFunction) {
+	if _, ok := r.handlers[taskName]; ok {
+		r.duplicates = append(r.duplicates, taskName)
+		return
+	}
+	r.handlers[taskName] = fn
+}
+
+// After declares that taskName consumes the output of the tasks deps, such as
+// a task mapping ${create_user_ref.output.user_id} after create_user_task.
+// ShutdownOrder stops it after them.
+func (r *HandlerRegistry) After(taskName string, deps ...string) {
+	r.after[taskName] = append(r.after[taskName], deps...)
+}
+
+// Validate returns an error naming the task names registered more than once,
+// or the tasks whose After declarations form a cycle.
+func (r *HandlerRegistry) Validate() error {
+	if len(r.duplicates) > 0 {
+		return fmt.Errorf("duplicate handler registration for %s", strings.Join(r.duplicates, ", "))
+	}
+	if _, cyclic := r.dependencyOrder(); len(cyclic) > 0 {
+		return fmt.Errorf("cyclic task dependencies between %s", strings.Join(cyclic, ", "))
+	}
+	return nil
+}
+
+// ShutdownOrder returns the registered task names ordered so that each task
+// comes after the tasks it was declared After, i.e. in workflow order: the
+// tasks feeding others stop taking work first, and the tasks downstream
+// drain what was already produced for them. Unrelated tasks are ordered by
+// name, and dependencies on tasks not in the registry are ignored. Tasks on a
+// dependency cycle, which Validate rejects, come last.
+func (r *HandlerRegistry) ShutdownOrder() []string {
+	order, cyclic := r.dependencyOrder()
+	return append(order, cyclic...)
+}
+
+// dependencyOrder sorts the registered task names after their dependencies,
+// returning the tasks it could not sort because of a cycle separately.
+func (r *HandlerRegistry) dependencyOrder() (order, cyclic []string) {
+	names := r.Names()
+	pending := make(map[string]int, len(names))
+	dependents := map[string][]string{}
+	for _, taskName := range names {
+		for _, dep := range r.after[taskName] {
+			if _, ok := r.handlers[dep]; ok && dep != taskName {
+				pending[taskName]++
+				dependents[dep] = append(dependents[dep], taskName)
+			}
+		}
+	}
+	done := make(map[string]bool, len(names))
+	for progress := true; progress; {
+		progress = false
+		for _, taskName := range names {
+			if done[taskName] || pending[taskName] > 0 {
+				continue
+			}
+			done[taskName] = true
+			order = append(order, taskName)
+			for _, dependent := range dependents[taskName] {
+				pending[dependent]--
+			}
+			// Restart from the first name so ties stay ordered by name
+			progress = true
+			break
+		}
+	}
+	for _, taskName := range names {
+		if !done[taskName] {
+			cyclic = append(cyclic, taskName)
+		}
+	}
+	return order, cyclic
+}
+
+// Only returns a registry of the handlers of taskNames, along with the names
+// in taskNames that have no handler.
+func (r *HandlerRegistry) Only(taskNames []string) (*HandlerRegistry, []string) {
+	out := newHandlerRegistry()
+	var unknown []string
+	for _, taskName := range taskNames {
+		fn, ok := r.handlers[taskName]
+		if !ok {
+			unknown = append(unknown, taskName)
+			continue
+		}
+		out.handlers[taskName] = fn
+		out.after[taskName] = r.after[taskName]
+	}
+	return out, unknown
+}
+
+// Names returns the registered task names, sorted.
+func (r *HandlerRegistry) Names() []string {
+	names := make([]string, 0, len(r.handlers))
+	for taskName := range r.handlers {
+		names = append(names, taskName)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Handlers returns the registered handlers by task name, each wrapped with
+// wrap when not nil.
+func (r *HandlerRegistry) Handlers(wrap func(func(*model.Task) (interface{}, error)) func(*model.Task) (interface{}, error)) map[string]model.ExecuteTaskFunction {
+	out := make(map[string]model.ExecuteTaskFunction, len(r.handlers))
+	for taskName, fn := range r.handlers {
+		if wrap != nil {
+			fn = wrap(fn)
+		}
+		out[taskName] = fn
+	}
+	return out
+}
+
+// RegisterAll registers a worker with sup for every handler, wrapped with
+// wrap, taking polling configuration from the Conductor task definitions (see
+// workerWithDefConfig) and polling the domain domains sets for the task, if
+// any. Either every worker is registered or none is.
+func (r *HandlerRegistry) RegisterAll(sup *supervisor, metadataClient *client.MetadataResourceApiService, wrap func(func(*model.Task) (interface{}, error)) func(*model.Task) (interface{}, error), domains map[string]string) error {
+	workers := make([]worker.Worker, 0, len(r.handlers))
+	for _, taskName := range r.Names() {
+		w := workerWithDefConfig(metadataClient, taskName, wrap(r.handlers[taskName]))
+		if domain, ok := domains[taskName]; ok {
+			log.Printf("Supervisor: %s polls domain %s", taskName, domain)
+			w = w.With(worker.WithDomain(domain))
+		}
+		workers = append(workers, w)
+	}
+	return sup.RegisterWorkersAtomic(workers...)
+}
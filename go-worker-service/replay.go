@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// ReplayResult compares the result of a replayed task with its recording.
+type ReplayResult struct {
+	TaskID   string `json:"task_id"`
+	TaskType string `json:"task_type"`
+	// Error is set when the replayed result could not be compared.
+	Error string `json:"error,omitempty"`
+	// Diffs lists what differs, as "key: recorded <value>, replayed
+	// <value>": the status, the reason for incompletion and each output key.
+	Diffs []string `json:"diffs,omitempty"`
+}
+
+// ReplayFile runs handler against every task recorded in path (see
+// RECORD_FILE) without contacting Conductor and compares each result with the
+// recorded one. The recording holds the results actually sent, so handler
+// must be wrapped by the same middleware chain, configured the same way.
+// Outputs are compared decompressed, redacted like the recording and after a
+// JSON round trip, and the worker metadata added by RESULT_METADATA is
+// ignored.
+func ReplayFile(path string, handler model.ExecuteTaskFunction) ([]ReplayResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open record file: %w", err)
+	}
+	defer f.Close()
+
+	var results []ReplayResult
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var rec taskRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return results, fmt.Errorf("invalid record on line %d: %w", line, err)
+		}
+		if rec.Task == nil {
+			return results, fmt.Errorf("record on line %d has no task", line)
+		}
+		res := ReplayResult{TaskID: rec.Task.TaskId, TaskType: rec.Task.TaskDefName}
+		recorded := rec.Result
+		if recorded == nil {
+			recorded = &model.TaskResult{}
+		}
+		out, err := handler(rec.Task)
+		if res.Diffs, err = diffResults(recorded, redactResult(resultOf(rec.Task, out, err))); err != nil {
+			res.Error = err.Error()
+		}
+		results = append(results, res)
+	}
+	if err := scanner.Err(); err != nil {
+		return results, fmt.Errorf("failed to read record file: %w", err)
+	}
+	return results, nil
+}
+
+// replayInTx runs fn on t with every database access of t, including its
+// state writes, going through tx (see taskDB). The changes of a failing task
+// are undone; those of the others stay visible to the tasks replayed after
+// them, as they were when recording, until the caller rolls tx back.
+func replayInTx(tx *sql.Tx, t *model.Task, fn model.ExecuteTaskFunction) (res interface{}, err error) {
+	if _, err := tx.Exec("SAVEPOINT replay_task"); err != nil {
+		return nil, fmt.Errorf("failed to start replay of task %s: %w", t.TaskId, err)
+	}
+	replayTxs.Store(t.TaskId, tx)
+	failed := true
+	defer func() {
+		replayTxs.Delete(t.TaskId)
+		if !failed {
+			if _, relErr := tx.Exec("RELEASE SAVEPOINT replay_task"); relErr == nil {
+				return
+			}
+		}
+		if _, rbErr := tx.Exec("ROLLBACK TO SAVEPOINT replay_task"); rbErr != nil {
+			log.Printf("Replay: failed to undo task %s: %v", t.TaskId, rbErr)
+		}
+	}()
+	res, err = fn(t)
+	failed = err != nil
+	return res, err
+}
+
+// diffResults compares a recorded task result with a replayed one.
+func diffResults(recorded, replayed *model.TaskResult) ([]string, error) {
+	want, err := normalizeOutput(recorded.OutputData)
+	if err != nil {
+		return nil, err
+	}
+	got, err := normalizeOutput(replayed.OutputData)
+	if err != nil {
+		return nil, err
+	}
+	delete(want, workerMetadataKey)
+	delete(got, workerMetadataKey)
+
+	var diffs []string
+	if recorded.Status != replayed.Status {
+		diffs = append(diffs, fmt.Sprintf("status: recorded %s, replayed %s", recorded.Status, replayed.Status))
+	}
+	if recorded.ReasonForIncompletion != replayed.ReasonForIncompletion {
+		diffs = append(diffs, fmt.Sprintf("reason: recorded %q, replayed %q", recorded.ReasonForIncompletion, replayed.ReasonForIncompletion))
+	}
+	keys := make(map[string]struct{})
+	for k := range want {
+		keys[k] = struct{}{}
+	}
+	for k := range got {
+		keys[k] = struct{}{}
+	}
+	var outputDiffs []string
+	for k := range keys {
+		if !reflect.DeepEqual(want[k], got[k]) {
+			outputDiffs = append(outputDiffs, fmt.Sprintf("%s: recorded %v, replayed %v", k, want[k], got[k]))
+		}
+	}
+	sort.Strings(outputDiffs)
+	return append(diffs, outputDiffs...), nil
+}
+
+// normalizeOutput decompresses out and round-trips it through JSON so that
+// recorded and replayed values have the same Go types.
+func normalizeOutput(out map[string]interface{}) (map[string]interface{}, error) {
+	if out[compressionKey] != nil {
+		var err error
+		if out, err = decompressPayload(out); err != nil {
+			return nil, err
+		}
+	}
+	raw, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+	var norm map[string]interface{}
+	if err := json.Unmarshal(raw, &norm); err != nil {
+		return nil, err
+	}
+	if norm == nil {
+		norm = map[string]interface{}{}
+	}
+	return norm, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// reportFlushRows is how many CSV rows are written between flushes of a
+// streamed report.
+const reportFlushRows = 500
+
+// parseReportTime parses a report range bound, either an RFC 3339 time or a
+// date such as 2024-05-01, taken as midnight UTC.
+func parseReportTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
+
+// taskReportHandler streams the tasks recorded in worker_state that finished,
+// i.e. aren't STARTED or IN_PROGRESS, with updated_at in [from, to) as CSV:
+// task_id, workflow_id, task_type, status and duration_ms, the time its
+// handler took by the start and finish times recorded with its state, empty
+// for the rows recorded before they were. Rows are written as they are read,
+// so large ranges aren't held in memory.
+func taskReportHandler(w http.ResponseWriter, r *http.Request) {
+	from, err := parseReportTime(r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, "from must be an RFC 3339 time or a YYYY-MM-DD date", http.StatusBadRequest)
+		return
+	}
+	to, err := parseReportTime(r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, "to must be an RFC 3339 time or a YYYY-MM-DD date", http.StatusBadRequest)
+		return
+	}
+	if !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+	if db == nil {
+		http.Error(w, "Database unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	rows, err := db.QueryContext(r.Context(), `
+		SELECT task_id, COALESCE(workflow_id, ''), COALESCE(task_type, ''), COALESCE(status, ''),
+		       (EXTRACT(EPOCH FROM finished_at - started_at) * 1000)::BIGINT
+		FROM worker_state
+		WHERE updated_at >= $1 AND updated_at < $2 AND status NOT IN ('STARTED', 'IN_PROGRESS')
+		ORDER BY updated_at`, from, to)
+	if err != nil {
+		log.Printf("Admin: failed to query task report: %v", err)
+		http.Error(w, "Failed to query worker state", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="tasks.csv"`)
+	out := csv.NewWriter(w)
+	out.Write([]string{"task_id", "workflow_id", "task_type", "status", "duration_ms"})
+	flusher, _ := w.(http.Flusher)
+	n := 0
+	for rows.Next() {
+		var taskID, workflowID, taskType, status string
+		var durationMs sql.NullInt64
+		if err := rows.Scan(&taskID, &workflowID, &taskType, &status, &durationMs); err != nil {
+			log.Printf("Admin: failed to read task report row: %v", err)
+			return
+		}
+		duration := ""
+		if durationMs.Valid {
+			duration = strconv.FormatInt(durationMs.Int64, 10)
+		}
+		out.Write([]string{taskID, workflowID, taskType, status, duration})
+		if n++; n%reportFlushRows == 0 {
+			out.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+	out.Flush()
+	// Headers are sent by now; a truncated report can only be logged
+	if err := rows.Err(); err != nil {
+		log.Printf("Admin: task report truncated after %d rows: %v", n, err)
+		return
+	}
+	if err := out.Error(); err != nil {
+		log.Printf("Admin: failed to write task report after %d rows: %v", n, err)
+	}
+}
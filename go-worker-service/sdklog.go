@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sdklog "github.com/conductor-sdk/conductor-go/sdk/log"
+)
+
+// sdkLogHook is installed as the conductor-go logger. It forwards log lines to
+// next and feeds the runner activity the SDK only reports through its logs,
+// polls and task updates, into the supervisor. It matches the exact messages
+// of the vendored SDK; TestSDKLogMessagesVendored checks they are still there.
+type sdkLogHook struct {
+	next sdklog.Logger
+	sup  *supervisor
+
+	// sampleEvery > 1 forwards only one of every sampleEvery debug lines per
+	// task; debugCounts maps task names to their *atomic.Uint64 line count.
+	sampleEvery uint64
+	debugCounts *sync.Map
+
+	// errorThrottle > 0 forwards repeats of the same poll error of a task at
+	// most once per interval; pollErrors tracks the repeats.
+	errorThrottle time.Duration
+	pollErrors    *pollErrorLog
+}
+
+func newSDKLogHook(next sdklog.Logger, sup *supervisor) *sdkLogHook {
+	return &sdkLogHook{next: next, sup: sup, debugCounts: &sync.Map{}, pollErrors: &pollErrorLog{seen: map[string]*pollErrorEntry{}}}
+}
+
+// WithLogSampling makes the hook forward only one of every `every` debug lines
+// logged for each task, such as the per-poll and per-execution lines of the
+// TaskRunner. Info, warning and error lines are always forwarded.
+func (h *sdkLogHook) WithLogSampling(every int) *sdkLogHook {
+	if every > 1 {
+		h.sampleEvery = uint64(every)
+	}
+	return h
+}
+
+// sampled reports whether a debug line should be forwarded.
+func (h *sdkLogHook) sampled(args []interface{}) bool {
+	if h.sampleEvery <= 1 {
+		return true
+	}
+	taskName, ok := logField(args, "taskName").(string)
+	if !ok {
+		if taskName, ok = logField(args, "taskDefName").(string); !ok {
+			return true
+		}
+	}
+	v, ok := h.debugCounts.Load(taskName)
+	if !ok {
+		v, _ = h.debugCounts.LoadOrStore(taskName, new(atomic.Uint64))
+	}
+	return (v.(*atomic.Uint64).Add(1)-1)%h.sampleEvery == 0
+}
+
+// WithErrorLogThrottle makes the hook forward a poll error of a task at most
+// once per d while the same error repeats, such as throughout a Conductor
+// outage. The next line forwarded for it carries the number of repeats it
+// dropped meanwhile. Different errors, and other error lines, are always
+// forwarded.
+func (h *sdkLogHook) WithErrorLogThrottle(d time.Duration) *sdkLogHook {
+	if d > 0 {
+		h.errorThrottle = d
+	}
+	return h
+}
+
+// pollErrorLogMessage is logged by the TaskRunner for each failed poll, before
+// it backs off.
+const pollErrorLogMessage = "Generic error occurred"
+
+// pausedPollError is the error of the pollErrorLogMessage lines the
+// TaskRunner logs in place of polling a paused task.
+const pausedPollError = "worker is paused"
+
+// pollErrorLog tracks when each poll error of a task was last forwarded.
+type pollErrorLog struct {
+	mu   sync.Mutex
+	seen map[string]*pollErrorEntry
+}
+
+type pollErrorEntry struct {
+	logged     time.Time
+	suppressed int
+}
+
+// throttled reports whether a poll error line should be dropped, and
+// otherwise how many repeats of it were dropped since it was last forwarded.
+func (h *sdkLogHook) throttled(args []interface{}) (bool, int) {
+	if h.errorThrottle <= 0 {
+		return false, 0
+	}
+	key := fmt.Sprintf("%v\x00%v", logField(args, "taskName"), logField(args, "error"))
+	now := time.Now()
+	h.pollErrors.mu.Lock()
+	defer h.pollErrors.mu.Unlock()
+	e, ok := h.pollErrors.seen[key]
+	if !ok {
+		// Forget errors that stopped repeating so the map doesn't grow with
+		// every distinct error message
+		for k, old := range h.pollErrors.seen {
+			if old.suppressed == 0 && now.Sub(old.logged) >= h.errorThrottle {
+				delete(h.pollErrors.seen, k)
+			}
+		}
+		h.pollErrors.seen[key] = &pollErrorEntry{logged: now}
+		return false, 0
+	}
+	if now.Sub(e.logged) < h.errorThrottle {
+		e.suppressed++
+		return true, 0
+	}
+	suppressed := e.suppressed
+	e.logged, e.suppressed = now, 0
+	return false, suppressed
+}
+
+// pollLogMessage is logged by the TaskRunner right before each batch poll.
+const pollLogMessage = "Polling for task"
+
+func (h *sdkLogHook) Debug(args ...interface{}) {
+	switch logMessage(args) {
+	case pollLogMessage:
+		if taskName, ok := logField(args, "taskName").(string); ok {
+			h.sup.pollStarted(taskName)
+		}
+	case polledLogMessage:
+		taskName, _ := logField(args, "taskName").(string)
+		count, _ := logField(args, "count").(int)
+		h.sup.pollReturned(taskName, count)
+	case updatedLogMessage:
+		if taskID, ok := logField(args, "taskId").(string); ok {
+			h.sup.taskUpdated(taskID)
+			h.sup.resultDelivered(taskID)
+		}
+		if taskName, ok := logField(args, "taskDefName").(string); ok {
+			h.sup.recordUpdateSuccess(taskName)
+		}
+	}
+	if h.sampled(args) {
+		h.next.Debug(args...)
+	}
+}
+
+func (h *sdkLogHook) Info(args ...interface{})  { h.next.Info(args...) }
+func (h *sdkLogHook) Warn(args ...interface{})  { h.next.Warn(args...) }
+func (h *sdkLogHook) Fatal(args ...interface{}) { h.next.Fatal(args...) }
+
+func (h *sdkLogHook) Error(args ...interface{}) {
+	switch logMessage(args) {
+	case updateFailedLogMessage:
+		if taskName, ok := logField(args, "taskName").(string); ok {
+			h.sup.recordUpdateFailure(taskName)
+		}
+		if taskID, ok := logField(args, "taskId").(string); ok {
+			h.sup.resultDelivered(taskID)
+		}
+	case pollErrorLogMessage:
+		if taskName, ok := logField(args, "taskName").(string); ok {
+			h.sup.pollFailed(taskName, logField(args, "error"))
+		}
+		// A paused task logs this every 200ms; the supervisor already logs
+		// why it paused the task
+		if fmt.Sprint(logField(args, "error")) == pausedPollError {
+			if h.sampled(args) {
+				h.next.Debug(args...)
+			}
+			return
+		}
+		drop, suppressed := h.throttled(args)
+		if drop {
+			return
+		}
+		if suppressed > 0 {
+			args = append(args[:len(args):len(args)], "suppressed", suppressed)
+		}
+	}
+	h.next.Error(args...)
+}
+
+func (h *sdkLogHook) With(values ...interface{}) sdklog.Logger {
+	cp := *h
+	cp.next = h.next.With(values...)
+	return &cp
+}
+
+// logMessage returns the leading message of an SDK log call, which is followed
+// by key/value pairs.
+func logMessage(args []interface{}) string {
+	if len(args)%2 == 0 {
+		return ""
+	}
+	msg, _ := args[0].(string)
+	return msg
+}
+
+// logField returns the value logged under key, or nil.
+func logField(args []interface{}, key string) interface{} {
+	for i := len(args) % 2; i+1 < len(args); i += 2 {
+		if args[i] == key {
+			return args[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdklog "github.com/conductor-sdk/conductor-go/sdk/log"
+)
+
+// recordingLogger is an SDK logger keeping the lines logged to it as
+// "LEVEL message key=value ...".
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) record(level string, args []interface{}) {
+	line := level
+	if len(args)%2 == 1 {
+		line += " " + fmt.Sprint(args[0])
+	}
+	for i := len(args) % 2; i+1 < len(args); i += 2 {
+		line += fmt.Sprintf(" %v=%v", args[i], args[i+1])
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, line)
+}
+
+func (l *recordingLogger) Debug(args ...interface{})         { l.record("DEBUG", args) }
+func (l *recordingLogger) Info(args ...interface{})          { l.record("INFO", args) }
+func (l *recordingLogger) Warn(args ...interface{})          { l.record("WARN", args) }
+func (l *recordingLogger) Error(args ...interface{})         { l.record("ERROR", args) }
+func (l *recordingLogger) Fatal(args ...interface{})         { l.record("FATAL", args) }
+func (l *recordingLogger) With(...interface{}) sdklog.Logger { return l }
+
+func TestErrorLogThrottle(t *testing.T) {
+	const window = 50 * time.Millisecond
+	// pollError is a poll error logged for task after waiting wait
+	type pollError struct {
+		task string
+		err  string
+		wait time.Duration
+	}
+	tests := []struct {
+		name     string
+		throttle time.Duration
+		logged   []pollError
+		want     []string
+	}{
+		{
+			name:   "repeats within the window dropped",
+			logged: []pollError{{task: "task_a", err: "boom"}, {task: "task_a", err: "boom"}, {task: "task_a", err: "boom"}},
+			want:   []string{"ERROR Generic error occurred taskName=task_a error=boom"},
+		},
+		{
+			name: "first repeat after the window carries the suppressed count",
+			logged: []pollError{
+				{task: "task_a", err: "boom"},
+				{task: "task_a", err: "boom"},
+				{task: "task_a", err: "boom"},
+				{task: "task_a", err: "boom", wait: 2 * window},
+				{task: "task_a", err: "boom"},
+			},
+			want: []string{
+				"ERROR Generic error occurred taskName=task_a error=boom",
+				"ERROR Generic error occurred taskName=task_a error=boom suppressed=2",
+			},
+		},
+		{
+			name:   "repeat after a quiet window has no suppressed count",
+			logged: []pollError{{task: "task_a", err: "boom"}, {task: "task_a", err: "boom", wait: 2 * window}},
+			want: []string{
+				"ERROR Generic error occurred taskName=task_a error=boom",
+				"ERROR Generic error occurred taskName=task_a error=boom",
+			},
+		},
+		{
+			name:   "different errors forwarded",
+			logged: []pollError{{task: "task_a", err: "boom"}, {task: "task_a", err: "bang"}, {task: "task_a", err: "boom"}},
+			want: []string{
+				"ERROR Generic error occurred taskName=task_a error=boom",
+				"ERROR Generic error occurred taskName=task_a error=bang",
+			},
+		},
+		{
+			name:   "same error of different tasks forwarded",
+			logged: []pollError{{task: "task_a", err: "boom"}, {task: "task_b", err: "boom"}},
+			want: []string{
+				"ERROR Generic error occurred taskName=task_a error=boom",
+				"ERROR Generic error occurred taskName=task_b error=boom",
+			},
+		},
+		{
+			name:   "paused task logged at debug unthrottled",
+			logged: []pollError{{task: "task_a", err: pausedPollError}, {task: "task_a", err: pausedPollError}},
+			want: []string{
+				"DEBUG Generic error occurred taskName=task_a error=worker is paused",
+				"DEBUG Generic error occurred taskName=task_a error=worker is paused",
+			},
+		},
+		{
+			name:     "throttle off",
+			throttle: -1,
+			logged:   []pollError{{task: "task_a", err: "boom"}, {task: "task_a", err: "boom"}},
+			want: []string{
+				"ERROR Generic error occurred taskName=task_a error=boom",
+				"ERROR Generic error occurred taskName=task_a error=boom",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle := tt.throttle
+			if throttle == 0 {
+				throttle = window
+			}
+			rec := &recordingLogger{}
+			h := newSDKLogHook(rec, newSupervisor(nil)).WithErrorLogThrottle(throttle)
+			for _, e := range tt.logged {
+				time.Sleep(e.wait)
+				h.Error(pollErrorLogMessage, "taskName", e.task, "error", e.err)
+			}
+			if !reflect.DeepEqual(rec.lines, tt.want) {
+				t.Errorf("forwarded\n%q\nwant\n%q", rec.lines, tt.want)
+			}
+		})
+	}
+}
+
+// TestSDKLogMessagesVendored fails when an SDK upgrade drops or rewords a log
+// message the worker derives state from, which would otherwise go unnoticed.
+func TestSDKLogMessagesVendored(t *testing.T) {
+	const runnerSources = "vendor/github.com/conductor-sdk/conductor-go/sdk/worker"
+	files, err := filepath.Glob(filepath.Join(runnerSources, "*.go"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no vendored SDK sources in %s: %v", runnerSources, err)
+	}
+	var sources strings.Builder
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("read %s: %v", file, err)
+		}
+		sources.Write(src)
+	}
+	tests := []struct {
+		name    string
+		message string
+	}{
+		{name: "poll started", message: pollLogMessage},
+		{name: "tasks polled", message: polledLogMessage},
+		{name: "result updated", message: updatedLogMessage},
+		{name: "result update failed", message: updateFailedLogMessage},
+		{name: "poll failed", message: pollErrorLogMessage},
+		{name: "paused poll", message: pausedPollError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(sources.String(), strconv.Quote(tt.message)) {
+				t.Errorf("vendored SDK no longer logs %q", tt.message)
+			}
+		})
+	}
+}
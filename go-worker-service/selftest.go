@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/antihax/optional"
+	"github.com/conductor-sdk/conductor-go/sdk/client"
+)
+
+// selfTestTimeout bounds each self-test check.
+const selfTestTimeout = 10 * time.Second
+
+// selfTestDomain is the task domain polled by the self-test. Nothing is
+// scheduled in it, so the polls exercise the poll endpoint without claiming
+// any task.
+const selfTestDomain = "selftest"
+
+// selfTestCheck is one check of the self-test.
+type selfTestCheck struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// runSelfTest pings the database, fetches the Conductor server version and
+// polls every task once without claiming work, printing a PASS/FAIL line per
+// check. It returns the process exit code: non-zero if any check failed.
+func runSelfTest(apiClient *client.APIClient, taskNames []string) int {
+	taskClient := &client.TaskResourceApiService{APIClient: apiClient}
+	checks := []selfTestCheck{
+		{"database", func(ctx context.Context) error {
+			connStr, _ := dbConnString()
+			conn, err := sql.Open("postgres", connStr)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			return conn.PingContext(ctx)
+		}},
+		{"conductor version", func(ctx context.Context) error {
+			v, _, err := client.NewVersionResourceAPIService(apiClient).GetVersion(ctx)
+			if err == nil {
+				fmt.Printf("      conductor %s\n", v)
+			}
+			return err
+		}},
+	}
+	for _, taskName := range taskNames {
+		taskName := taskName
+		checks = append(checks, selfTestCheck{"poll " + taskName, func(ctx context.Context) error {
+			t, _, err := taskClient.Poll(ctx, taskName, &client.TaskResourceApiPollOpts{
+				Workerid: optional.NewString("selftest"),
+				Domain:   optional.NewString(selfTestDomain),
+			})
+			if err == nil && t.TaskId != "" {
+				return fmt.Errorf("claimed task %s of domain %s", t.TaskId, selfTestDomain)
+			}
+			return err
+		}})
+	}
+
+	failed := 0
+	for _, c := range checks {
+		ctx, cancel := context.WithTimeout(context.Background(), selfTestTimeout)
+		err := c.run(ctx)
+		cancel()
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL  %s: %v\n", c.name, err)
+			continue
+		}
+		fmt.Printf("PASS  %s\n", c.name)
+	}
+	if failed > 0 {
+		fmt.Printf("self-test failed: %d of %d checks\n", failed, len(checks))
+		return 1
+	}
+	fmt.Printf("self-test passed: %d checks\n", len(checks))
+	return 0
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"container/heap"
+	"sync"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// taskSlots is a counting semaphore for task handlers. Freed slots go to the
+// waiting task that arrived first or, when byPriority is set, to the waiting
+// task of the highest workflow priority, oldest first among equals. Ordering
+// only applies among tasks already polled and waiting in this process.
+type taskSlots struct {
+	mu         sync.Mutex
+	free       int
+	byPriority bool
+	seq        uint64
+	waiting    slotWaiters
+}
+
+func newTaskSlots(n int, byPriority bool) *taskSlots {
+	return &taskSlots{free: n, byPriority: byPriority}
+}
+
+// acquire blocks until t holds a slot.
+func (s *taskSlots) acquire(t *model.Task) {
+	s.mu.Lock()
+	if s.free > 0 && len(s.waiting) == 0 {
+		s.free--
+		s.mu.Unlock()
+		return
+	}
+	w := &slotWaiter{seq: s.seq, ready: make(chan struct{})}
+	if s.byPriority {
+		w.priority = t.WorkflowPriority
+	}
+	s.seq++
+	heap.Push(&s.waiting, w)
+	s.mu.Unlock()
+	<-w.ready
+}
+
+// release frees a slot, handing it straight to the next waiting task if any.
+func (s *taskSlots) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.waiting) == 0 {
+		s.free++
+		return
+	}
+	close(heap.Pop(&s.waiting).(*slotWaiter).ready)
+}
+
+// slotWaiter is a task waiting for a slot.
+type slotWaiter struct {
+	priority int32
+	seq      uint64
+	ready    chan struct{}
+}
+
+// slotWaiters is a heap of waiting tasks, highest priority then lowest
+// sequence number first.
+type slotWaiters []*slotWaiter
+
+func (h slotWaiters) Len() int { return len(h) }
+
+func (h slotWaiters) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h slotWaiters) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *slotWaiters) Push(x interface{}) { *h = append(*h, x.(*slotWaiter)) }
+
+func (h *slotWaiters) Pop() interface{} {
+	old := *h
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return w
+}
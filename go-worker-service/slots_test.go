@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+func TestTaskSlotsOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		byPriority bool
+		priorities []int32
+		want       []string
+	}{
+		{name: "arrival order", priorities: []int32{1, 5, 3, 5}, want: []string{"w0", "w1", "w2", "w3"}},
+		{name: "priority order", byPriority: true, priorities: []int32{1, 5, 3, 5}, want: []string{"w1", "w3", "w2", "w0"}},
+		{name: "equal priorities oldest first", byPriority: true, priorities: []int32{2, 2, 2}, want: []string{"w0", "w1", "w2"}},
+		{name: "zero priority last", byPriority: true, priorities: []int32{0, 0, 1}, want: []string{"w2", "w0", "w1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slots := newTaskSlots(1, tt.byPriority)
+			slots.acquire(&model.Task{})
+
+			acquired := make(chan string)
+			for i, p := range tt.priorities {
+				task := &model.Task{TaskId: fmt.Sprintf("w%d", i), WorkflowPriority: p}
+				go func() {
+					slots.acquire(task)
+					acquired <- task.TaskId
+				}()
+				// Queue the waiters one at a time so their arrival order is known
+				waitFor(t, func() bool {
+					slots.mu.Lock()
+					defer slots.mu.Unlock()
+					return len(slots.waiting) == i+1
+				})
+			}
+
+			var got []string
+			for range tt.priorities {
+				slots.release()
+				select {
+				case id := <-acquired:
+					got = append(got, id)
+				case <-time.After(5 * time.Second):
+					t.Fatalf("no waiter acquired a released slot, got %v so far", got)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("slots went to %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaskSlotsCap(t *testing.T) {
+	tests := []struct {
+		name string
+		cap  int
+	}{
+		{name: "one slot", cap: 1},
+		{name: "three slots", cap: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slots := newTaskSlots(tt.cap, true)
+			for i := 0; i < tt.cap; i++ {
+				slots.acquire(&model.Task{})
+			}
+			done := make(chan struct{})
+			go func() {
+				slots.acquire(&model.Task{WorkflowPriority: 9})
+				close(done)
+			}()
+			select {
+			case <-done:
+				t.Fatal("acquired a slot beyond the cap")
+			case <-time.After(50 * time.Millisecond):
+			}
+			slots.release()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("waiter not handed the released slot")
+			}
+			slots.mu.Lock()
+			defer slots.mu.Unlock()
+			if slots.free != 0 {
+				t.Errorf("free = %d after the hand-off, want 0", slots.free)
+			}
+		})
+	}
+}
+
+// waitFor waits up to a few seconds for cond to hold.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
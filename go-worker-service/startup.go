@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+
+	sdklog "github.com/conductor-sdk/conductor-go/sdk/log"
+	"github.com/conductor-sdk/conductor-go/sdk/settings"
+)
+
+// startupConfig is the effective configuration logged by logStartupConfig.
+type startupConfig struct {
+	ConductorURL string                       `json:"conductor_url"`
+	AuthMode     string                       `json:"auth_mode"`
+	DBHost       string                       `json:"db_host"`
+	DBName       string                       `json:"db_name"`
+	DBSchema     string                       `json:"db_schema"`
+	Tasks        map[string]startupTaskConfig `json:"tasks"`
+}
+
+// startupTaskConfig is the polling configuration of one task in startupConfig.
+type startupTaskConfig struct {
+	Domain         string `json:"domain,omitempty"`
+	BatchSize      int    `json:"batch_size"`
+	PollIntervalMs int64  `json:"poll_interval_ms"`
+	// PollTimeoutMs is negative when the server default is used.
+	PollTimeoutMs int64 `json:"poll_timeout_ms"`
+}
+
+// logStartupConfig logs the effective configuration, including the polling of
+// every registered task, as a single JSON line through the SDK logger. The
+// database password is left out and credentials in the Conductor URL are
+// redacted.
+func logStartupConfig(apiURL string, auth *settings.AuthenticationSettings, sup *supervisor) {
+	cfg := startupConfig{
+		ConductorURL: redactURL(apiURL),
+		AuthMode:     authMode(auth),
+		DBHost:       getEnv("DB_HOST", "localhost"),
+		DBName:       getEnv("DB_NAME", "conductor"),
+		DBSchema:     getEnv("DB_SCHEMA", "public"),
+		Tasks:        map[string]startupTaskConfig{},
+	}
+	for taskName, tc := range sup.DumpConfig().Tasks {
+		cfg.Tasks[taskName] = startupTaskConfig{
+			Domain:         tc.Domain,
+			BatchSize:      tc.BatchSize,
+			PollIntervalMs: tc.PollIntervalMs,
+			PollTimeoutMs:  tc.PollTimeoutMs,
+		}
+	}
+	line, err := json.Marshal(cfg)
+	if err != nil {
+		sdklog.Error("Failed to encode startup config", "error", err)
+		return
+	}
+	sdklog.Info("Startup config", "config", string(line))
+}
+
+// authMode names how the Conductor client authenticates.
+func authMode(auth *settings.AuthenticationSettings) string {
+	if auth == nil || auth.IsEmpty() {
+		return "none"
+	}
+	return "key"
+}
+
+// redactURL returns rawURL with any password replaced, or a placeholder if it
+// doesn't parse.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return redactedValue
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+	"github.com/conductor-sdk/conductor-go/sdk/worker"
+)
+
+// ExportState returns the DumpConfig snapshot as JSON, for ImportState to
+// restore on another instance.
+func (s *supervisor) ExportState() []byte {
+	data, _ := json.Marshal(s.DumpConfig())
+	return data
+}
+
+// Handoff exports the state of the runner, then pauses every task so a new
+// instance can take over with the same configuration. Running handlers finish
+// normally.
+func (s *supervisor) Handoff() []byte {
+	data := s.ExportState()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for taskName := range s.workers {
+		s.pauseLocked(taskName, pauseReasonHandoff)
+	}
+	log.Printf("Supervisor: exported state and paused %d task(s) for handoff", len(s.workers))
+	return data
+}
+
+// ImportState registers a worker for every task of a snapshot written by
+// ExportState, with the batch size, poll interval and timeout, domain and
+// in-flight cap it had, and pauses the tasks an operator had paused. Batch
+// size boosts are temporary and aren't carried over. A task is wired to the
+// handler of the same name in handlers, so every task of the snapshot must be
+// there; tasks missing from the snapshot aren't registered. It returns the
+// names of the registered tasks.
+func (s *supervisor) ImportState(data []byte, handlers map[string]model.ExecuteTaskFunction) ([]string, error) {
+	var cfg RunnerConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse runner state: %w", err)
+	}
+	taskNames := make([]string, 0, len(cfg.Tasks))
+	for taskName := range cfg.Tasks {
+		if handlers[taskName] == nil {
+			return nil, fmt.Errorf("no handler registered for task %s", taskName)
+		}
+		taskNames = append(taskNames, taskName)
+	}
+	sort.Strings(taskNames)
+
+	workers := make([]worker.Worker, 0, len(taskNames))
+	s.mu.Lock()
+	for _, taskName := range taskNames {
+		tc := cfg.Tasks[taskName]
+		opts := []worker.Option{
+			worker.WithBatchSize(tc.BatchSize - tc.BoostDelta),
+			worker.WithPollInterval(time.Duration(tc.PollIntervalMs) * time.Millisecond),
+			worker.WithDomain(tc.Domain),
+		}
+		if tc.PollTimeoutMs > 0 {
+			opts = append(opts, worker.WithPollTimeout(time.Duration(tc.PollTimeoutMs)*time.Millisecond))
+		}
+		workers = append(workers, worker.NewWorker(taskName, handlers[taskName], opts...))
+		if tc.MaxInFlight > 0 {
+			s.setMaxInFlightLocked(taskName, tc.MaxInFlight)
+		}
+	}
+	s.mu.Unlock()
+	if err := s.RegisterWorkersAtomic(workers...); err != nil {
+		return nil, err
+	}
+	// The runner resumes the tasks it starts, so pause them once registered
+	s.mu.Lock()
+	for _, taskName := range taskNames {
+		for _, reason := range cfg.Tasks[taskName].PauseReasons {
+			if reason == pauseReasonOperator {
+				s.pauseLocked(taskName, pauseReasonOperator)
+			}
+		}
+	}
+	s.mu.Unlock()
+	log.Printf("Supervisor: imported state of %d task(s)", len(taskNames))
+	return taskNames, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// stateWriteHealth tracks failed writes of the worker_state table. Task
+// processing never waits on it; it only feeds the admin readiness check.
+type stateWriteHealth struct {
+	failures    atomic.Int64
+	consecutive atomic.Int64
+	lastError   atomic.Int64 // Unix nanoseconds, 0 before the first failure
+	// threshold is the number of consecutive failures from which the worker
+	// reports not ready; 0 never does.
+	threshold int64
+}
+
+// StateWriteHealth is a point-in-time copy of stateWriteHealth.
+type StateWriteHealth struct {
+	Ready               bool       `json:"ready"`
+	FailedWrites        int64      `json:"failed_state_writes"`
+	ConsecutiveFailures int64      `json:"consecutive_failures"`
+	LastErrorTime       *time.Time `json:"last_error_time,omitempty"`
+}
+
+// stateHealth is the health of the worker_state writes of this process.
+var stateHealth = &stateWriteHealth{}
+
+func (h *stateWriteHealth) recordSuccess() {
+	h.consecutive.Store(0)
+}
+
+func (h *stateWriteHealth) recordFailure() {
+	h.failures.Add(1)
+	h.consecutive.Add(1)
+	h.lastError.Store(time.Now().UnixNano())
+}
+
+// Snapshot returns the current counters. The worker stays not ready until a
+// state write succeeds again.
+func (h *stateWriteHealth) Snapshot() StateWriteHealth {
+	snap := StateWriteHealth{
+		FailedWrites:        h.failures.Load(),
+		ConsecutiveFailures: h.consecutive.Load(),
+	}
+	snap.Ready = h.threshold <= 0 || snap.ConsecutiveFailures < h.threshold
+	if ns := h.lastError.Load(); ns != 0 {
+		t := time.Unix(0, ns)
+		snap.LastErrorTime = &t
+	}
+	return snap
+}
+
+// readiness is the body of the admin readiness check.
+type readiness struct {
+	StateWriteHealth
+	// Polled reports whether a poll of Conductor has succeeded yet.
+	Polled bool `json:"polled"`
+}
+
+// readyHandler answers 200 once a poll of Conductor has succeeded, while
+// worker state is being recorded, and 503 before the first successful poll or
+// once the consecutive failed state writes reach the threshold. The body
+// carries the counters either way.
+func readyHandler(sup *supervisor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body := readiness{StateWriteHealth: stateHealth.Snapshot(), Polled: sup.IsReady()}
+		body.Ready = body.Ready && body.Polled
+		w.Header().Set("Content-Type", "application/json")
+		if !body.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(body)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// successRateRetention bounds the window SuccessRate can look back over.
+const successRateRetention = time.Hour
+
+// successRateWindow is the window of the success rate reported by Stats.
+const successRateWindow = 5 * time.Minute
+
+// outcomeBucket counts the executions that ended within one second, and the
+// time the tasks that started running within it waited after being polled.
+type outcomeBucket struct {
+	sec       int64
+	completed int64
+	failed    int64
+	waits     int64
+	waitTotal time.Duration
+	waitMax   time.Duration
+}
+
+// taskStats holds the runtime counters of one task name. Timestamps are stored
+// as Unix nanoseconds so they can be updated without locking.
+type taskStats struct {
+	lastPoll atomic.Int64
+	lastTask atomic.Int64
+
+	// allocTick counts executions to pick those sampled by measureAllocs;
+	// allocSamples, allocBytes and allocObjects sum up the samples.
+	allocTick    atomic.Uint64
+	allocSamples atomic.Uint64
+	allocBytes   atomic.Uint64
+	allocObjects atomic.Uint64
+
+	// outcomes is a ring of per-second buckets covering successRateRetention.
+	mu       sync.Mutex
+	outcomes [int(successRateRetention / time.Second)]outcomeBucket
+}
+
+// TaskStats is a point-in-time copy of the counters of one task name.
+type TaskStats struct {
+	// Domain is the task domain the worker polls, empty for the default one.
+	// The runner serves each task name in a single domain, so stats of the
+	// same task in several domains come from separate worker processes.
+	Domain       string    `json:"domain,omitempty"`
+	LastPollTime time.Time `json:"last_poll_time"`
+	LastTaskTime time.Time `json:"last_task_time"`
+	InFlight     int       `json:"in_flight"`
+	BatchSize    int       `json:"batch_size"`
+	// PauseReasons lists why polling is paused, such as update_failures
+	// after repeated failed result updates.
+	PauseReasons []string `json:"pause_reasons,omitempty"`
+	// UpdateFailures counts the result updates that failed in a row.
+	UpdateFailures int `json:"update_failures,omitempty"`
+	// SuccessRate is the share of executions that completed rather than
+	// failed over the last successRateWindow, nil without any.
+	SuccessRate *float64 `json:"success_rate,omitempty"`
+	// QueueWaitAvgMs and QueueWaitMaxMs are the average and longest time
+	// tasks waited between being polled and their handler starting, e.g. for
+	// a global concurrency slot, over the last successRateWindow; nil without
+	// any.
+	QueueWaitAvgMs *float64 `json:"queue_wait_avg_ms,omitempty"`
+	QueueWaitMaxMs *float64 `json:"queue_wait_max_ms,omitempty"`
+	// AllocBytesAvg and AllocObjectsAvg are the average heap allocations of
+	// the AllocSamples handler executions sampled since startup (see
+	// HANDLER_ALLOC_SAMPLE_EVERY); nil when sampling is off or none was.
+	AllocBytesAvg   *float64 `json:"alloc_bytes_avg,omitempty"`
+	AllocObjectsAvg *float64 `json:"alloc_objects_avg,omitempty"`
+	AllocSamples    uint64   `json:"alloc_samples,omitempty"`
+	// Tags are the worker tags, the same for every task.
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+func (st *taskStats) snapshot() TaskStats {
+	snap := TaskStats{
+		LastPollTime: unixNanoTime(st.lastPoll.Load()),
+		LastTaskTime: unixNanoTime(st.lastTask.Load()),
+	}
+	if rate := st.successRate(successRateWindow); !math.IsNaN(rate) {
+		snap.SuccessRate = &rate
+	}
+	if avg, max, ok := st.queueWait(successRateWindow); ok {
+		avgMs, maxMs := float64(avg)/float64(time.Millisecond), float64(max)/float64(time.Millisecond)
+		snap.QueueWaitAvgMs, snap.QueueWaitMaxMs = &avgMs, &maxMs
+	}
+	if n := st.allocSamples.Load(); n > 0 {
+		bytes, objects := float64(st.allocBytes.Load())/float64(n), float64(st.allocObjects.Load())/float64(n)
+		snap.AllocBytesAvg, snap.AllocObjectsAvg, snap.AllocSamples = &bytes, &objects, n
+	}
+	return snap
+}
+
+// bucketLocked returns the bucket of the current second, emptied if it last
+// held an older second. The caller must hold st.mu.
+func (st *taskStats) bucketLocked() *outcomeBucket {
+	sec := time.Now().Unix()
+	b := &st.outcomes[sec%int64(len(st.outcomes))]
+	if b.sec != sec {
+		*b = outcomeBucket{sec: sec}
+	}
+	return b
+}
+
+func (st *taskStats) recordOutcome(completed bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	b := st.bucketLocked()
+	if completed {
+		b.completed++
+	} else {
+		b.failed++
+	}
+}
+
+func (st *taskStats) recordQueueWait(d time.Duration) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	b := st.bucketLocked()
+	b.waits++
+	b.waitTotal += d
+	if d > b.waitMax {
+		b.waitMax = d
+	}
+}
+
+// queueWait returns the average and longest queue wait over the last window,
+// or false if no task started running within it.
+func (st *taskStats) queueWait(window time.Duration) (avg, max time.Duration, ok bool) {
+	now := time.Now().Unix()
+	from := now - int64(window/time.Second)
+	var n int64
+	var total time.Duration
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, b := range st.outcomes {
+		if b.sec > from && b.sec <= now && b.waits > 0 {
+			n += b.waits
+			total += b.waitTotal
+			if b.waitMax > max {
+				max = b.waitMax
+			}
+		}
+	}
+	if n == 0 {
+		return 0, 0, false
+	}
+	return total / time.Duration(n), max, true
+}
+
+func (st *taskStats) successRate(window time.Duration) float64 {
+	now := time.Now().Unix()
+	from := now - int64(window/time.Second)
+	var completed, failed int64
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, b := range st.outcomes {
+		if b.sec > from && b.sec <= now {
+			completed += b.completed
+			failed += b.failed
+		}
+	}
+	if completed+failed == 0 {
+		return math.NaN()
+	}
+	return float64(completed) / float64(completed+failed)
+}
+
+func unixNanoTime(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+func (s *supervisor) statsFor(taskName string) *taskStats {
+	if st, ok := s.stats.Load(taskName); ok {
+		return st.(*taskStats)
+	}
+	st, _ := s.stats.LoadOrStore(taskName, &taskStats{})
+	return st.(*taskStats)
+}
+
+func (s *supervisor) recordPoll(taskName string) {
+	s.statsFor(taskName).lastPoll.Store(time.Now().UnixNano())
+}
+
+func (s *supervisor) recordTask(taskName string) {
+	s.statsFor(taskName).lastTask.Store(time.Now().UnixNano())
+}
+
+// recordOutcome counts a finished execution of taskName as completed or
+// failed. Tasks left IN_PROGRESS, such as those awaiting a callback, aren't
+// counted.
+func (s *supervisor) recordOutcome(taskName string, res interface{}, err error) {
+	if err != nil {
+		s.statsFor(taskName).recordOutcome(false)
+		return
+	}
+	if r, ok := res.(*model.TaskResult); ok {
+		switch r.Status {
+		case model.FailedTask, model.FailedWithTerminalErrorTask:
+			s.statsFor(taskName).recordOutcome(false)
+		case model.CompletedTask:
+			s.statsFor(taskName).recordOutcome(true)
+		}
+		return
+	}
+	s.statsFor(taskName).recordOutcome(true)
+}
+
+// SuccessRate returns the share of the executions of taskName that ended in
+// the last window which completed rather than failed, or NaN when none ended.
+// The window is capped at successRateRetention and has a one second
+// resolution.
+func (s *supervisor) SuccessRate(taskName string, window time.Duration) float64 {
+	return s.statsFor(taskName).successRate(window)
+}
+
+// LastPollTime returns when a poll of Conductor for taskName was last found
+// to have succeeded, or the zero time if none has. Polls returning tasks are
+// seen as they return; empty ones only when the next poll starts (see
+// pollStarted), so for idle tasks it lags by a poll interval.
+func (s *supervisor) LastPollTime(taskName string) time.Time {
+	return unixNanoTime(s.statsFor(taskName).lastPoll.Load())
+}
+
+// LastTaskTime returns when a task of taskName was last handed to its handler,
+// or the zero time if none has been.
+func (s *supervisor) LastTaskTime(taskName string) time.Time {
+	return unixNanoTime(s.statsFor(taskName).lastTask.Load())
+}
+
+// Stats returns a snapshot of the counters of every task name seen so far.
+func (s *supervisor) Stats() map[string]TaskStats {
+	out := make(map[string]TaskStats)
+	s.stats.Range(func(k, v interface{}) bool {
+		out[k.(string)] = v.(*taskStats).snapshot()
+		return true
+	})
+	batchSizes := s.runner.GetBatchSizeForAll()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for taskName, st := range out {
+		if w, ok := s.workers[taskName]; ok {
+			st.Domain = w.Options().Domain
+		}
+		st.PauseReasons = s.pauseReasonsOf(taskName)
+		st.UpdateFailures = s.updateFailures[taskName]
+		st.InFlight = s.inFlight[taskName]
+		st.BatchSize = batchSizes[taskName]
+		st.Tags = s.tags
+		out[taskName] = st
+	}
+	return out
+}
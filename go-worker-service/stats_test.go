@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+func TestQueueWaitStats(t *testing.T) {
+	// hold is how long the first task keeps the only slot while the others
+	// wait for it
+	const hold = 50 * time.Millisecond
+	tests := []struct {
+		name     string
+		tasks    int
+		rejected bool
+		wantWait bool
+		// wantMax and wantAvg are lower bounds
+		wantMax time.Duration
+		wantAvg time.Duration
+	}{
+		{name: "no tasks"},
+		{name: "rejected before starting", tasks: 1, rejected: true},
+		{name: "free slot", tasks: 1, wantWait: true},
+		{name: "waited for the slot", tasks: 2, wantWait: true, wantMax: hold, wantAvg: hold / 2},
+		{name: "several waited for the slot", tasks: 3, wantWait: true, wantMax: hold, wantAvg: 2 * hold / 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sup := newSupervisor(nil)
+			slots := newTaskSlots(1, false)
+			release := make(chan struct{})
+			handler := func(*model.Task) (interface{}, error) {
+				<-release
+				return nil, nil
+			}
+			fn := sup.track("queued_task", withConcurrencyLimit(slots, withExecutionStart(handler)))
+			if tt.rejected {
+				// Fails before it gets past the limits, e.g. over a rate limit
+				fn = sup.track("queued_task", func(*model.Task) (interface{}, error) { return nil, errTest })
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < tt.tasks; i++ {
+				wg.Add(1)
+				go func(taskID string) {
+					defer wg.Done()
+					fn(&model.Task{TaskId: taskID, TaskDefName: "queued_task"})
+				}(fmt.Sprintf("t%d", i))
+				if !tt.rejected {
+					// Queue the tasks one at a time behind the first one
+					waiting := i
+					waitFor(t, func() bool {
+						slots.mu.Lock()
+						defer slots.mu.Unlock()
+						return slots.free == 0 && len(slots.waiting) == waiting
+					})
+				}
+			}
+			if tt.tasks > 1 {
+				time.Sleep(hold)
+			}
+			close(release)
+			wg.Wait()
+
+			snap := sup.statsFor("queued_task").snapshot()
+			if !tt.wantWait {
+				if snap.QueueWaitAvgMs != nil || snap.QueueWaitMaxMs != nil {
+					t.Errorf("queue wait avg %v, max %v, want none", snap.QueueWaitAvgMs, snap.QueueWaitMaxMs)
+				}
+				return
+			}
+			if snap.QueueWaitAvgMs == nil || snap.QueueWaitMaxMs == nil {
+				t.Fatal("queue wait missing")
+			}
+			avg := time.Duration(*snap.QueueWaitAvgMs * float64(time.Millisecond))
+			max := time.Duration(*snap.QueueWaitMaxMs * float64(time.Millisecond))
+			if max < tt.wantMax || avg < tt.wantAvg {
+				t.Errorf("queue wait avg %s, max %s, want at least %s, %s", avg, max, tt.wantAvg, tt.wantMax)
+			}
+		})
+	}
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+	"github.com/conductor-sdk/conductor-go/sdk/worker"
+)
+
+// drainPollInterval is how often ShutdownInOrder checks for in-flight handlers.
+const drainPollInterval = 50 * time.Millisecond
+
+// updateAwaitTTL bounds how long a task stays in flight after its handler
+// returned, covering the SDK's update retries, in case the SDK never reports
+// the update.
+const updateAwaitTTL = completionStashTTL
+
+// awaitedUpdate is a task whose result update the supervisor awaits. token
+// tells apart deliveries of the same task id.
+type awaitedUpdate struct {
+	taskName string
+	token    *int
+}
+
+// supervisor layers runtime controls over the SDK TaskRunner. All methods are
+// safe for concurrent use.
+type supervisor struct {
+	runner *worker.TaskRunner
+
+	mu sync.Mutex
+	// workers holds every worker registered through the supervisor by task name.
+	workers map[string]worker.Worker
+	// stats maps task names to their *taskStats.
+	stats sync.Map
+	// inFlight counts the tasks polled but not yet updated per task: from the
+	// start of their handler until the SDK reports their result update, which
+	// awaitingUpdate maps their task id to the task name for.
+	inFlight       map[string]int
+	awaitingUpdate sync.Map
+	// boostTimers holds the pending reverts of BoostBatchSize per task.
+	boostTimers map[string]map[*time.Timer]int
+	// boostDelta is the net batch size currently added by boosts per task.
+	boostDelta map[string]int
+	// budgets holds the task budgets set by SetMaxTasks.
+	budgets map[string]*taskBudget
+	// pollTimingWarned records the tasks already warned about by checkPollTiming.
+	pollTimingWarned map[string]bool
+	// pauseReasons holds, per task, why polling is paused. The runner is
+	// paused while a task has any reason.
+	pauseReasons map[string]map[string]bool
+	// pausedAll is set between PauseAll and ResumeAll.
+	pausedAll bool
+	// maxInFlight caps inFlight per task (see setMaxInFlightLocked).
+	maxInFlight map[string]int
+	// pollIntervals holds the configured poll interval per task, which the
+	// runner's may be stretched from.
+	pollIntervals map[string]time.Duration
+	// backpressure holds the throttling level of the tasks a backpressure gate
+	// slows down (see SetBackpressureGate); throttleDelta is the net batch
+	// size it withholds per task.
+	backpressure  map[string]int
+	throttleDelta map[string]int
+	// idleBackoff, when set, stretches the poll interval of tasks by their
+	// count of consecutive emptyPolls (see SetIdleBackoff).
+	idleBackoff *idleBackoff
+	emptyPolls  map[string]int
+	// updateFailures counts the consecutive failed result updates per task;
+	// see SetUpdateFailurePause.
+	updateFailures         map[string]int
+	updateFailureTimers    map[string]*time.Timer
+	updateFailureThreshold int
+	updateFailureCooldown  time.Duration
+	// onCompletion is called with the results Conductor accepted; completions
+	// maps task ids to the *pendingCompletion awaiting the update.
+	onCompletion func(string, *model.TaskResult)
+	completions  sync.Map
+	// polled is set by the first successful poll; pollsPending maps task names
+	// to an *atomic.Bool set while a poll of the task awaits its outcome.
+	polled       atomic.Bool
+	pollsPending sync.Map
+	// allocSampleEvery is the rate of handler executions whose allocations
+	// are measured; see withAllocSampling.
+	allocSampleEvery uint64
+	// tags identify the worker in stats; see withWorkerTags.
+	tags map[string]string
+	// autoStart makes RegisterWorker start polling right away. When false,
+	// workers wait in pending until Start is called.
+	autoStart bool
+	started   bool
+	pending   []worker.Worker
+}
+
+// supervisorOption configures a supervisor created by newSupervisor.
+type supervisorOption func(*supervisor)
+
+// withAutoStart sets whether RegisterWorker starts polling immediately
+// (the default) or defers it until Start.
+func withAutoStart(autoStart bool) supervisorOption {
+	return func(s *supervisor) { s.autoStart = autoStart }
+}
+
+func newSupervisor(runner *worker.TaskRunner, opts ...supervisorOption) *supervisor {
+	s := &supervisor{
+		runner:              runner,
+		workers:             make(map[string]worker.Worker),
+		inFlight:            make(map[string]int),
+		boostTimers:         make(map[string]map[*time.Timer]int),
+		boostDelta:          make(map[string]int),
+		budgets:             make(map[string]*taskBudget),
+		pollTimingWarned:    make(map[string]bool),
+		pauseReasons:        make(map[string]map[string]bool),
+		maxInFlight:         make(map[string]int),
+		pollIntervals:       make(map[string]time.Duration),
+		backpressure:        make(map[string]int),
+		throttleDelta:       make(map[string]int),
+		emptyPolls:          make(map[string]int),
+		updateFailures:      make(map[string]int),
+		updateFailureTimers: make(map[string]*time.Timer),
+		autoStart:           true,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// RegisterError reports which worker failed to register.
+type RegisterError struct {
+	TaskName string
+	Err      error
+}
+
+func (e *RegisterError) Error() string {
+	return fmt.Sprintf("failed to register worker %s: %v", e.TaskName, e.Err)
+}
+
+func (e *RegisterError) Unwrap() error { return e.Err }
+
+// RegisterWorker registers w with the runner and starts polling for it, or,
+// when auto start is disabled and Start hasn't been called yet, records it for
+// Start. Failures are returned as *RegisterError.
+func (s *supervisor) RegisterWorker(w worker.Worker) error {
+	if w == nil {
+		return &RegisterError{Err: fmt.Errorf("worker is nil")}
+	}
+	s.mu.Lock()
+	if !s.autoStart && !s.started {
+		s.pending = append(s.pending, w)
+		s.mu.Unlock()
+		return nil
+	}
+	s.mu.Unlock()
+	return s.startWorker(w)
+}
+
+// Start starts polling for every worker registered while auto start was
+// disabled. Like RegisterWorkersAtomic, either all of them start or none do.
+// Workers registered afterwards start immediately.
+func (s *supervisor) Start() error {
+	s.mu.Lock()
+	s.started = true
+	pending := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+	return s.RegisterWorkersAtomic(pending...)
+}
+
+func (s *supervisor) startWorker(w worker.Worker) error {
+	if err := s.runner.RegisterWorker(s.tracked(w)); err != nil {
+		return &RegisterError{TaskName: w.TaskName(), Err: err}
+	}
+	s.mu.Lock()
+	s.workers[w.TaskName()] = w
+	s.pollIntervals[w.TaskName()], _ = s.runner.GetPollIntervalForTask(w.TaskName())
+	// Task definitions and imported state set the poll timing too
+	s.checkPollTiming(w.TaskName())
+	if s.pausedAll {
+		s.pauseLocked(w.TaskName(), pauseReasonAll)
+	}
+	// The runner resumes every task it starts, so pauses recorded before the
+	// worker started, e.g. by ImportState while Start is pending, must be
+	// reasserted
+	if len(s.pauseReasons[w.TaskName()]) > 0 {
+		s.runner.Pause(w.TaskName())
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *supervisor) isRegistered(taskName string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.workers[taskName]
+	return ok
+}
+
+// tracked returns a copy of w whose handler is counted in inFlight.
+func (s *supervisor) tracked(w worker.Worker) worker.Worker {
+	o := w.Options()
+	return worker.NewWorker(w.TaskName(), s.withBudget(w.TaskName(), s.track(w.TaskName(), w.Handler())),
+		worker.WithBatchSize(o.BatchSize),
+		worker.WithPollInterval(o.PollInterval),
+		worker.WithPollTimeout(o.PollTimeout),
+		worker.WithDomain(o.Domain),
+		worker.WithBaseContext(o.BaseContext),
+	)
+}
+
+func (s *supervisor) track(taskName string, fn model.ExecuteTaskFunction) model.ExecuteTaskFunction {
+	return func(t *model.Task) (interface{}, error) {
+		polled := time.Now()
+		s.recordTask(taskName)
+		s.mu.Lock()
+		s.inFlight[taskName]++
+		if max := s.maxInFlight[taskName]; max > 0 && s.inFlight[taskName] >= max {
+			s.pauseLocked(taskName, pauseReasonMaxInFlight)
+		}
+		s.mu.Unlock()
+		defer executionStarts.Delete(t.TaskId)
+		returned := false
+		defer func() {
+			// A panicking handler gets no result update
+			if !returned {
+				s.taskDone(taskName)
+			}
+		}()
+		res, err := s.measureAllocs(taskName, fn, t)
+		returned = true
+		s.awaitUpdate(taskName, t.TaskId)
+		if started, ok := executionStarts.Load(t.TaskId); ok {
+			s.statsFor(taskName).recordQueueWait(started.(time.Time).Sub(polled))
+		}
+		s.recordOutcome(taskName, res, err)
+		s.stashCompletion(t, res, err)
+		return res, err
+	}
+}
+
+// awaitUpdate keeps taskID counted in inFlight until the SDK reports its
+// result update through resultDelivered, or for updateAwaitTTL at most.
+func (s *supervisor) awaitUpdate(taskName, taskID string) {
+	token := new(int)
+	// A task delivered again before the update of its previous delivery
+	// was reported only counts once
+	if prev, loaded := s.awaitingUpdate.Swap(taskID, awaitedUpdate{taskName: taskName, token: token}); loaded {
+		s.taskDone(prev.(awaitedUpdate).taskName)
+	}
+	time.AfterFunc(updateAwaitTTL, func() {
+		if s.awaitingUpdate.CompareAndDelete(taskID, awaitedUpdate{taskName: taskName, token: token}) {
+			s.taskDone(taskName)
+		}
+	})
+}
+
+// resultDelivered records that the SDK is done updating the result of taskID,
+// whether the update succeeded or failed for good.
+func (s *supervisor) resultDelivered(taskID string) {
+	if v, ok := s.awaitingUpdate.LoadAndDelete(taskID); ok {
+		s.taskDone(v.(awaitedUpdate).taskName)
+	}
+}
+
+// taskDone stops counting a task of taskName in inFlight.
+func (s *supervisor) taskDone(taskName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight[taskName]--
+	if s.inFlight[taskName] < s.maxInFlight[taskName] {
+		s.resumeLocked(taskName, pauseReasonMaxInFlight)
+	}
+}
+
+// InFlight returns the number of tasks of taskName polled but not yet updated.
+func (s *supervisor) InFlight(taskName string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inFlight[taskName]
+}
+
+// RegisterWorkers registers each worker in order, stopping at the first failure.
+func (s *supervisor) RegisterWorkers(workers ...worker.Worker) error {
+	for _, w := range workers {
+		if err := s.RegisterWorker(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterWorkersAtomic registers each worker in order. If any registration
+// fails, the workers already registered by this call are shut down before the
+// error is returned, so either all workers poll or none do.
+func (s *supervisor) RegisterWorkersAtomic(workers ...worker.Worker) error {
+	for i, w := range workers {
+		if err := s.RegisterWorker(w); err != nil {
+			for _, started := range workers[:i] {
+				log.Printf("Supervisor: rolling back registration of %s", started.TaskName())
+				s.Shutdown(started.TaskName())
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// BoostBatchSize increases the batch size of taskName by delta and reverts the
+// increase once duration has elapsed. Overlapping boosts compose: each one
+// reverts only its own delta.
+func (s *supervisor) BoostBatchSize(taskName string, delta int, duration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if delta < 1 {
+		return fmt.Errorf("boost batch size for %s: delta must be positive", taskName)
+	}
+	if err := s.setBatchSizeLocked(taskName, s.batchSizeLocked(taskName)+delta); err != nil {
+		return fmt.Errorf("boost batch size for %s: %w", taskName, err)
+	}
+	if s.boostTimers[taskName] == nil {
+		s.boostTimers[taskName] = make(map[*time.Timer]int)
+	}
+	s.boostDelta[taskName] += delta
+	var timer *time.Timer
+	timer = time.AfterFunc(duration, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.boostTimers[taskName][timer]; !ok {
+			return
+		}
+		delete(s.boostTimers[taskName], timer)
+		s.boostDelta[taskName] -= delta
+		if err := s.setBatchSizeLocked(taskName, max(s.batchSizeLocked(taskName)-delta, 0)); err != nil {
+			log.Printf("Supervisor: failed to revert batch size boost for %s: %v", taskName, err)
+			return
+		}
+		log.Printf("Supervisor: reverted batch size boost of %d for %s (net boost %d)", delta, taskName, s.boostDelta[taskName])
+	})
+	s.boostTimers[taskName][timer] = delta
+	log.Printf("Supervisor: boosted batch size of %s by %d for %s (net boost %d)", taskName, delta, duration, s.boostDelta[taskName])
+	return nil
+}
+
+// Shutdown cancels pending boosts for taskName and stops polling it.
+func (s *supervisor) Shutdown(taskName string) {
+	s.mu.Lock()
+	for timer := range s.boostTimers[taskName] {
+		timer.Stop()
+	}
+	delete(s.boostTimers, taskName)
+	delete(s.boostDelta, taskName)
+	delete(s.pauseReasons, taskName)
+	delete(s.pollIntervals, taskName)
+	delete(s.backpressure, taskName)
+	delete(s.throttleDelta, taskName)
+	delete(s.emptyPolls, taskName)
+	delete(s.workers, taskName)
+	if len(s.workers) == 0 {
+		s.polled.Store(false)
+	}
+	s.mu.Unlock()
+	s.pollsPending.Delete(taskName)
+	s.runner.Shutdown(taskName)
+}
+
+// ShutdownInOrder shuts down each task in the given order, waiting up to
+// perTaskTimeout for its in-flight handlers to finish before moving on to the
+// next one. This gives dependent tasks a predictable drain order within this
+// process; it does not stop Conductor from handing pending tasks to other
+// replicas.
+func (s *supervisor) ShutdownInOrder(perTaskTimeout time.Duration, taskNames ...string) {
+	for _, taskName := range taskNames {
+		s.Shutdown(taskName)
+		deadline := time.Now().Add(perTaskTimeout)
+		for s.InFlight(taskName) > 0 {
+			if time.Now().After(deadline) {
+				log.Printf("Supervisor: %d task(s) of %s still running after %s, continuing shutdown", s.InFlight(taskName), taskName, perTaskTimeout)
+				break
+			}
+			time.Sleep(drainPollInterval)
+		}
+		log.Printf("Supervisor: %s shut down", taskName)
+	}
+}
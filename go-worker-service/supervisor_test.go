@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+	"github.com/conductor-sdk/conductor-go/sdk/worker"
+)
+
+func TestRegisterWorkersAtomic(t *testing.T) {
+	noop := func(*model.Task) (interface{}, error) { return nil, nil }
+	tests := []struct {
+		name           string
+		workers        []worker.Worker
+		wantErr        bool
+		wantRegistered []string
+		wantAbsent     []string
+	}{
+		{
+			name:           "all registered",
+			workers:        []worker.Worker{testWorker("task_a", noop), testWorker("task_b", noop)},
+			wantRegistered: []string{"task_a", "task_b"},
+		},
+		{
+			name:       "failure rolls back the workers before it",
+			workers:    []worker.Worker{testWorker("task_a", noop), testWorker("task_b", noop), nil},
+			wantErr:    true,
+			wantAbsent: []string{"task_a", "task_b"},
+		},
+		{
+			name:       "failure first registers none",
+			workers:    []worker.Worker{nil, testWorker("task_a", noop)},
+			wantErr:    true,
+			wantAbsent: []string{"task_a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sup := newTestSupervisor(t, newFakeConductor(t))
+			err := sup.RegisterWorkersAtomic(tt.workers...)
+			var regErr *RegisterError
+			if tt.wantErr != errors.As(err, &regErr) {
+				t.Fatalf("err = %v, want a *RegisterError: %v", err, tt.wantErr)
+			}
+			for _, taskName := range tt.wantRegistered {
+				if !sup.isRegistered(taskName) {
+					t.Errorf("%s not registered", taskName)
+				}
+			}
+			for _, taskName := range tt.wantAbsent {
+				if sup.isRegistered(taskName) {
+					t.Errorf("%s still registered", taskName)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// workerTagPattern matches tag names usable as Prometheus labels.
+var workerTagPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// workerTags identify this worker, e.g. {"team": "identity", "version": "1.4"},
+// for attributing its work (WORKER_TAGS). Empty without tags.
+var workerTags map[string]string
+
+// parseWorkerTags parses a comma-separated list of name=value pairs such as
+// "team=identity,service=onboarding". Names must be valid Prometheus label
+// names other than the task and domain labels.
+func parseWorkerTags(raw string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("malformed tag %q, want name=value", entry)
+		}
+		if !workerTagPattern.MatchString(name) || name == "task" || name == "domain" {
+			return nil, fmt.Errorf("invalid tag name %q", name)
+		}
+		if _, dup := tags[name]; dup {
+			return nil, fmt.Errorf("tag %s set twice", name)
+		}
+		tags[name] = value
+	}
+	return tags, nil
+}
+
+// tagFields renders tags as name=value fields sorted by name.
+func tagFields(tags map[string]string) []string {
+	fields := make([]string, 0, len(tags))
+	for name, value := range tags {
+		fields = append(fields, name+"="+value)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// withWorkerTags attaches tags to the supervisor stats and the metrics built
+// from them.
+func withWorkerTags(tags map[string]string) supervisorOption {
+	return func(s *supervisor) { s.tags = tags }
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/conductor-sdk/conductor-go/sdk/client"
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+	"github.com/conductor-sdk/conductor-go/sdk/worker"
+)
+
+// Polling defaults used when the task definition carries no hints.
+const (
+	defaultBatchSize    = 1
+	defaultPollInterval = 100 * time.Millisecond
+)
+
+// handlerTimeoutMargin is taken off a task definition's responseTimeoutSeconds
+// so a handler gives up, and its failure is reported, before Conductor times
+// the task out itself.
+const handlerTimeoutMargin = 2 * time.Second
+
+// handlerTimeouts caches the handler timeout of each task name, derived from
+// its task definition. Zero means the definition was unavailable or sets no
+// response timeout, and handlers run without a deadline.
+var handlerTimeouts sync.Map
+
+// retryLimits caches the retryCount of each task name whose task definition
+// could be fetched.
+var retryLimits sync.Map
+
+// RegisterWorkerWithDefConfig fetches the Conductor task definition of taskName
+// and registers handler with polling derived from it:
+//
+//   - concurrentExecLimit sets the batch size;
+//   - rateLimitPerFrequency/rateLimitFrequencyInSeconds spread polls evenly over
+//     the rate limit window.
+//
+// Missing hints, or a definition that cannot be fetched, fall back to the
+// defaults of one task every 100ms.
+func (s *supervisor) RegisterWorkerWithDefConfig(metadataClient *client.MetadataResourceApiService, taskName string, handler model.ExecuteTaskFunction) error {
+	return s.RegisterWorker(workerWithDefConfig(metadataClient, taskName, handler))
+}
+
+// workerWithDefConfig builds the worker registered by RegisterWorkerWithDefConfig
+// without registering it.
+func workerWithDefConfig(metadataClient *client.MetadataResourceApiService, taskName string, handler model.ExecuteTaskFunction) worker.Worker {
+	batchSize, pollInterval := defaultBatchSize, defaultPollInterval
+	def, _, err := metadataClient.GetTaskDef(context.Background(), taskName)
+	if err != nil {
+		log.Printf("Supervisor: task def for %s unavailable, using defaults: %v", taskName, err)
+	} else {
+		batchSize, pollInterval = pollConfigFromTaskDef(def)
+	}
+	cacheTaskDef(taskName, def, err)
+	log.Printf("Supervisor: %s polls %d task(s) every %s", taskName, batchSize, pollInterval)
+	return worker.NewWorker(taskName, handler,
+		worker.WithBatchSize(batchSize),
+		worker.WithPollInterval(pollInterval),
+	)
+}
+
+// pollConfigFromTaskDef derives the batch size and poll interval from def.
+func pollConfigFromTaskDef(def model.TaskDef) (int, time.Duration) {
+	batchSize, pollInterval := defaultBatchSize, defaultPollInterval
+	if def.ConcurrentExecLimit > 0 {
+		batchSize = int(def.ConcurrentExecLimit)
+	}
+	if def.RateLimitPerFrequency > 0 && def.RateLimitFrequencyInSeconds > 0 {
+		window := time.Duration(def.RateLimitFrequencyInSeconds) * time.Second
+		if interval := window * time.Duration(batchSize) / time.Duration(def.RateLimitPerFrequency); interval > pollInterval {
+			pollInterval = interval
+		}
+	}
+	return batchSize, pollInterval
+}
+
+// loadTaskDef fetches the task definition of taskName, unless already cached,
+// to derive the timeout and retry limit of its handlers.
+func loadTaskDef(metadataClient *client.MetadataResourceApiService, taskName string) {
+	if _, ok := handlerTimeouts.Load(taskName); ok {
+		return
+	}
+	def, _, err := metadataClient.GetTaskDef(context.Background(), taskName)
+	if err != nil {
+		log.Printf("Supervisor: task def for %s unavailable: %v", taskName, err)
+	}
+	cacheTaskDef(taskName, def, err)
+}
+
+// cacheTaskDef caches the handler timeout and retry limit of taskName from
+// def, or no timeout and an unknown retry limit when fetching def failed with
+// err.
+func cacheTaskDef(taskName string, def model.TaskDef, err error) {
+	var timeout time.Duration
+	if err == nil {
+		timeout = handlerTimeoutFromTaskDef(def)
+		retryLimits.Store(taskName, def.RetryCount)
+	}
+	if timeout > 0 {
+		log.Printf("Supervisor: %s handlers time out after %s", taskName, timeout)
+	}
+	handlerTimeouts.Store(taskName, timeout)
+}
+
+// retryLimit returns the cached retryCount of taskName, false if unknown.
+func retryLimit(taskName string) (int32, bool) {
+	if v, ok := retryLimits.Load(taskName); ok {
+		return v.(int32), true
+	}
+	return 0, false
+}
+
+// handlerTimeout returns the cached handler timeout of taskName, zero if none.
+func handlerTimeout(taskName string) time.Duration {
+	if v, ok := handlerTimeouts.Load(taskName); ok {
+		return v.(time.Duration)
+	}
+	return 0
+}
+
+// handlerTimeoutFromTaskDef derives the handler timeout from def: its response
+// timeout less handlerTimeoutMargin, or half of it for response timeouts too
+// short to take the margin off.
+func handlerTimeoutFromTaskDef(def model.TaskDef) time.Duration {
+	if def.ResponseTimeoutSeconds <= 0 {
+		return 0
+	}
+	timeout := time.Duration(def.ResponseTimeoutSeconds) * time.Second
+	if timeout <= 2*handlerTimeoutMargin {
+		return timeout / 2
+	}
+	return timeout - handlerTimeoutMargin
+}
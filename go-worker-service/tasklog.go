@@ -0,0 +1,97 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// taskLogs maps the id of each task running under withTaskLogs to its *taskLogBuffer.
+var taskLogs sync.Map
+
+// taskLogBuffer accumulates the execution log messages of one task.
+type taskLogBuffer struct {
+	mu   sync.Mutex
+	logs []model.TaskExecLog
+}
+
+// taskLog attaches msg to the execution log of t, shown with the task in the
+// Conductor UI. It is safe to call from goroutines started by the handler;
+// messages logged outside withTaskLogs, or after the handler returned, are dropped.
+func taskLog(t *model.Task, msg string) {
+	v, ok := taskLogs.Load(t.TaskId)
+	if !ok {
+		return
+	}
+	buf := v.(*taskLogBuffer)
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	buf.logs = append(buf.logs, model.TaskExecLog{
+		Log:         msg,
+		TaskId:      t.TaskId,
+		CreatedTime: time.Now().UnixMilli(),
+	})
+}
+
+// withTaskLogs wraps a worker handler so that messages passed to taskLog during
+// its execution are sent with the task result.
+func withTaskLogs(fn func(*model.Task) (interface{}, error)) func(*model.Task) (interface{}, error) {
+	return func(t *model.Task) (interface{}, error) {
+		buf := &taskLogBuffer{}
+		taskLogs.Store(t.TaskId, buf)
+		// Panicking handlers must not leave their buffer behind
+		defer taskLogs.Delete(t.TaskId)
+		res, err := fn(t)
+
+		buf.mu.Lock()
+		logs := buf.logs
+		buf.mu.Unlock()
+		if len(logs) == 0 {
+			return res, err
+		}
+
+		var result *model.TaskResult
+		if err != nil {
+			result = model.NewTaskResultFromTaskWithError(t, err)
+		} else {
+			var cErr error
+			if result, cErr = model.GetTaskResultFromTaskExecutionOutput(t, res); cErr != nil {
+				return res, err
+			}
+		}
+		result.Logs = append(result.Logs, logs...)
+		return result, err
+	}
+}
+
+// requestIDKey is the task input key carrying the id of the API request that
+// started the workflow.
+const requestIDKey = "request_id"
+
+// traceparentKey is the task input key carrying the W3C trace context of the
+// API request that started the workflow.
+const traceparentKey = "traceparent"
+
+// taskLogger returns a logger whose lines are prefixed with the worker tags and
+// the request id and trace id found in the task input, so API and worker logs
+// can be correlated.
+func taskLogger(t *model.Task) *log.Logger {
+	fields := tagFields(workerTags)
+	if id, _ := t.InputData[requestIDKey].(string); id != "" {
+		fields = append(fields, "request_id="+id)
+	}
+	if tp, _ := t.InputData[traceparentKey].(string); tp != "" {
+		// version-traceid-spanid-flags
+		if parts := strings.Split(tp, "-"); len(parts) == 4 {
+			fields = append(fields, "trace_id="+parts[1])
+		}
+	}
+	prefix := ""
+	if len(fields) > 0 {
+		prefix = "[" + strings.Join(fields, " ") + "] "
+	}
+	return log.New(log.Writer(), prefix, log.Flags()|log.Lmsgprefix)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+func TestWithTaskLogs(t *testing.T) {
+	tests := []struct {
+		name       string
+		logs       []string
+		res        interface{}
+		err        error
+		wantResult bool
+		wantStatus model.TaskResultStatus
+	}{
+		{name: "no logs leaves the output", res: map[string]interface{}{"id": 1}},
+		{name: "logs attached to the output", logs: []string{"a", "b"}, res: map[string]interface{}{"id": 1}, wantResult: true, wantStatus: model.CompletedTask},
+		{name: "logs attached to a failure", logs: []string{"a"}, err: errTest, wantResult: true, wantStatus: model.FailedTask},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn := withTaskLogs(func(task *model.Task) (interface{}, error) {
+				for _, msg := range tt.logs {
+					taskLog(task, msg)
+				}
+				return tt.res, tt.err
+			})
+			task := &model.Task{TaskId: "t-" + tt.name}
+			res, err := fn(task)
+			if err != tt.err {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if _, ok := taskLogs.Load(task.TaskId); ok {
+				t.Error("log buffer left behind")
+			}
+			result, isResult := res.(*model.TaskResult)
+			if isResult != tt.wantResult {
+				t.Fatalf("res = %#v, want a task result: %v", res, tt.wantResult)
+			}
+			if !isResult {
+				return
+			}
+			if result.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", result.Status, tt.wantStatus)
+			}
+			if len(result.Logs) != len(tt.logs) {
+				t.Fatalf("got %d log(s), want %d", len(result.Logs), len(tt.logs))
+			}
+			for i, l := range result.Logs {
+				if l.Log != tt.logs[i] || l.TaskId != task.TaskId {
+					t.Errorf("log %d = %+v, want %q of task %s", i, l, tt.logs[i], task.TaskId)
+				}
+			}
+		})
+	}
+}
+
+func TestWithTaskLogsPanicReleasesBuffer(t *testing.T) {
+	task := &model.Task{TaskId: "t-panic"}
+	fn := withTaskLogs(func(task *model.Task) (interface{}, error) {
+		taskLog(task, "before panic")
+		panic("boom")
+	})
+	func() {
+		defer func() { recover() }()
+		fn(task)
+	}()
+	if _, ok := taskLogs.Load(task.TaskId); ok {
+		t.Error("log buffer left behind by a panicking handler")
+	}
+}
+
+func TestTrackReleasesExecutionStartOnPanic(t *testing.T) {
+	sup := newSupervisor(nil)
+	tests := []struct {
+		name    string
+		handler model.ExecuteTaskFunction
+	}{
+		{name: "returning handler", handler: func(*model.Task) (interface{}, error) { return nil, nil }},
+		{name: "panicking handler", handler: func(*model.Task) (interface{}, error) { panic("boom") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &model.Task{TaskId: "t-" + tt.name}
+			fn := sup.track("tracked_task", withExecutionStart(tt.handler))
+			func() {
+				defer func() { recover() }()
+				fn(task)
+			}()
+			if _, ok := executionStarts.Load(task.TaskId); ok {
+				t.Error("execution start left behind")
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// withEpochMillisTimes wraps a worker handler so that every time.Time in its
+// output, including those nested in structs, maps and slices, is sent as Unix
+// epoch milliseconds, matching Conductor's own timestamps (e.g.
+// Workflow.CreateTime) instead of the RFC 3339 strings encoding/json produces.
+// Structs are converted to maps as by walkJSON, so values implementing
+// json.Marshaler or encoding.TextMarshaler, such as enums with a custom
+// representation, are sent as they encode themselves.
+func withEpochMillisTimes(fn func(*model.Task) (interface{}, error)) func(*model.Task) (interface{}, error) {
+	return func(t *model.Task) (interface{}, error) {
+		res, err := fn(t)
+		if err != nil || res == nil {
+			return res, err
+		}
+		if tr, ok := res.(*model.TaskResult); ok {
+			if tr.OutputData != nil {
+				tr.OutputData = epochMillisValue(reflect.ValueOf(tr.OutputData)).(map[string]interface{})
+			}
+			return tr, nil
+		}
+		return epochMillisValue(reflect.ValueOf(res)), nil
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// epochMillisValue returns a copy of rv as by walkJSON, with every time.Time
+// replaced by its Unix epoch milliseconds.
+func epochMillisValue(rv reflect.Value) interface{} {
+	return walkJSON(rv, func(rv reflect.Value, _ *reflect.StructField) (interface{}, bool) {
+		if rv.Kind() == reflect.Ptr && rv.Type().Elem() == timeType && !rv.IsNil() {
+			rv = rv.Elem()
+		}
+		if rv.IsValid() && rv.Type() == timeType {
+			return rv.Interface().(time.Time).UnixMilli(), true
+		}
+		return nil, false
+	})
+}
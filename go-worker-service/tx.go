@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// txKey is the context key of the transaction started by withTx.
+type txKey struct{}
+
+// TxFromContext returns the transaction withTx stored in ctx, if any.
+func TxFromContext(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
+	return tx, ok
+}
+
+// dbConn is what handlers query through: db or a transaction.
+type dbConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// replayTxs maps the id of each task being replayed to the *sql.Tx its
+// database access goes through (see replayInTx).
+var replayTxs sync.Map
+
+// taskDB returns what the handlers of t and their middleware query through:
+// the replay transaction of t, if any, or db.
+func taskDB(t *model.Task) dbConn {
+	if tx, ok := replayTxs.Load(t.TaskId); ok {
+		return tx.(*sql.Tx)
+	}
+	return db
+}
+
+// withTx wraps a context-aware handler so that it runs inside a transaction on
+// conn, available through TxFromContext. The handler context is the task
+// context (see taskContext). The transaction is committed when the handler
+// succeeds and rolled back when it returns an error or panics. A replayed
+// task runs in its replay transaction instead, which is rolled back either
+// way.
+func withTx(conn *sql.DB, fn func(context.Context, *model.Task) (interface{}, error)) func(*model.Task) (interface{}, error) {
+	return func(t *model.Task) (interface{}, error) {
+		ctx := taskContext(t)
+		if tx, ok := replayTxs.Load(t.TaskId); ok {
+			return fn(context.WithValue(ctx, txKey{}, tx.(*sql.Tx)), t)
+		}
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		// Rolls back after a panic; a no-op once committed or rolled back
+		defer tx.Rollback()
+		res, err := fn(context.WithValue(ctx, txKey{}, tx), t)
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				taskLogger(t).Printf("failed to roll back transaction: %v", rbErr)
+			}
+			return res, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return res, nil
+	}
+}
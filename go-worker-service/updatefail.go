@@ -0,0 +1,60 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// updateFailedLogMessage is logged by the TaskRunner when a task result could
+// not be delivered to Conductor after all its retries.
+const updateFailedLogMessage = "failed to update task"
+
+// SetUpdateFailurePause pauses polling for a task once threshold results in a
+// row could not be delivered to Conductor, instead of executing more tasks
+// whose results would be lost too. Polling resumes after cooldown, or earlier
+// when a pending update succeeds; after a cooldown a single further failure
+// pauses the task again. A threshold of 0 disables the pause.
+func (s *supervisor) SetUpdateFailurePause(threshold int, cooldown time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updateFailureThreshold = threshold
+	s.updateFailureCooldown = cooldown
+}
+
+func (s *supervisor) recordUpdateFailure(taskName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updateFailures[taskName]++
+	if s.updateFailureThreshold <= 0 || s.updateFailures[taskName] < s.updateFailureThreshold {
+		return
+	}
+	if s.pauseReasons[taskName][pauseReasonUpdateFailures] {
+		return
+	}
+	log.Printf("Supervisor: %d result update(s) of %s failed in a row, pausing polling for %s", s.updateFailures[taskName], taskName, s.updateFailureCooldown)
+	s.pauseLocked(taskName, pauseReasonUpdateFailures)
+	var timer *time.Timer
+	timer = time.AfterFunc(s.updateFailureCooldown, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.updateFailureTimers[taskName] != timer {
+			return
+		}
+		delete(s.updateFailureTimers, taskName)
+		// Probe: the next failure pauses again straight away
+		s.updateFailures[taskName] = s.updateFailureThreshold - 1
+		s.resumeLocked(taskName, pauseReasonUpdateFailures)
+	})
+	s.updateFailureTimers[taskName] = timer
+}
+
+func (s *supervisor) recordUpdateSuccess(taskName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.updateFailures, taskName)
+	if timer, ok := s.updateFailureTimers[taskName]; ok {
+		timer.Stop()
+		delete(s.updateFailureTimers, taskName)
+	}
+	s.resumeLocked(taskName, pauseReasonUpdateFailures)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// validatable is implemented by handler outputs that can check themselves
+// before being sent to Conductor.
+type validatable interface {
+	Validate() error
+}
+
+// withOutputValidation wraps a worker handler so that an output implementing
+// validatable is validated before it is sent. An invalid output points at a
+// bug in the handler, so the task fails with a terminal error rather than
+// being retried with the same result.
+func withOutputValidation(fn func(*model.Task) (interface{}, error)) func(*model.Task) (interface{}, error) {
+	return func(t *model.Task) (interface{}, error) {
+		res, err := fn(t)
+		if err != nil {
+			return res, err
+		}
+		if v, ok := res.(validatable); ok {
+			if vErr := v.Validate(); vErr != nil {
+				return nil, model.NewNonRetryableError(fmt.Errorf("invalid output of task %s: %w", t.TaskDefName, vErr))
+			}
+		}
+		return res, nil
+	}
+}
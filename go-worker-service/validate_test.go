@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+func TestOutputValidation(t *testing.T) {
+	tests := []struct {
+		name         string
+		disabled     bool
+		out          interface{}
+		wantTerminal bool
+	}{
+		{name: "valid output", out: createUserOutput{UserID: 1}},
+		{name: "invalid output fails the task", out: createUserOutput{}, wantTerminal: true},
+		{name: "non-validatable output", out: map[string]interface{}{"user_id": 0}},
+		{name: "disabled", disabled: true, out: createUserOutput{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.disabled {
+				t.Setenv("OUTPUT_VALIDATION", "false")
+			}
+			fn := wrapHandler(func(*model.Task) (interface{}, error) { return tt.out, nil })
+			res, err := fn(&model.Task{TaskId: "t1", TaskDefName: "create_user_task"})
+			if _, terminal := err.(*model.NonRetryableError); terminal != tt.wantTerminal {
+				t.Fatalf("err = %v, want a *model.NonRetryableError: %v", err, tt.wantTerminal)
+			}
+			if !tt.wantTerminal && res == nil {
+				t.Error("output dropped")
+			}
+		})
+	}
+}
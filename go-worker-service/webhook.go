@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+const (
+	// webhookQueueSize bounds the notifications awaiting delivery; further
+	// ones are dropped.
+	webhookQueueSize = 100
+	// webhookAttempts bounds the deliveries of each notification.
+	webhookAttempts = 3
+	// webhookRetryDelay is the delay before the first retry, doubled after
+	// each attempt.
+	webhookRetryDelay = time.Second
+)
+
+// webhookNotification is a completion awaiting delivery.
+type webhookNotification struct {
+	taskName string
+	result   *model.TaskResult
+}
+
+// webhookClient posts completion notifications.
+var webhookClient = &http.Client{Timeout: 5 * time.Second}
+
+// newCompletionWebhook returns a completion handler that POSTs each task
+// result as JSON to url, with the task name in the X-Task-Type header. Only
+// results of taskNames are posted, or all of them when taskNames is empty.
+// Outputs are posted decompressed and redacted as by redactResult. Delivery
+// happens on a background goroutine and never delays or fails the task.
+func newCompletionWebhook(url string, taskNames map[string]bool) func(string, *model.TaskResult) {
+	queue := make(chan webhookNotification, webhookQueueSize)
+	go func() {
+		for n := range queue {
+			deliverWebhook(url, n.taskName, n.result)
+		}
+	}()
+	return func(taskName string, result *model.TaskResult) {
+		if len(taskNames) > 0 && !taskNames[taskName] {
+			return
+		}
+		select {
+		case queue <- webhookNotification{taskName: taskName, result: result}:
+		default:
+			log.Printf("Webhook: queue full, dropping notification for task %s", result.TaskId)
+		}
+	}
+}
+
+func deliverWebhook(url, taskName string, result *model.TaskResult) {
+	body, err := json.Marshal(redactResult(result))
+	if err != nil {
+		log.Printf("Webhook: failed to encode result of task %s: %v", result.TaskId, err)
+		return
+	}
+	delay := webhookRetryDelay
+	for attempt := 1; ; attempt++ {
+		err = postWebhook(url, taskName, body)
+		if err == nil {
+			return
+		}
+		if attempt == webhookAttempts {
+			break
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+	log.Printf("Webhook: giving up on task %s after %d attempts: %v", result.TaskId, webhookAttempts, err)
+}
+
+func postWebhook(url, taskName string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Task-Type", taskName)
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook responded with HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
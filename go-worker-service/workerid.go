@@ -0,0 +1,48 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+// resolveWorkerID returns the hostname, which the SDK uses as worker id, or a
+// generated id when hostname reports none, as in some sandboxes. The second
+// result reports whether the id was generated.
+//
+// The generated id only reaches task results: the SDK reads the hostname once
+// when it loads and sends it as the workerid of every poll, through an HTTP
+// client it builds itself, so polls still carry an empty worker id.
+func resolveWorkerID(hostname func() (string, error)) (string, bool) {
+	name, err := hostname()
+	if err == nil && name != "" {
+		log.Printf("Using hostname %s as worker id", name)
+		return name, false
+	}
+	id := newWorkerID()
+	log.Printf("Hostname unavailable (%v), using generated worker id %s in task results", err, id)
+	return id, true
+}
+
+// newWorkerID returns a random (version 4) UUID.
+func newWorkerID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		log.Printf("Failed to generate worker id: %v", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// workerIDDecorator returns a result decorator setting the worker id of results
+// that carry none. The SDK leaves it empty when the hostname is unavailable.
+func workerIDDecorator(id string) func(*model.TaskResult) {
+	return func(result *model.TaskResult) {
+		if result.WorkerId == "" {
+			result.WorkerId = id
+		}
+	}
+}
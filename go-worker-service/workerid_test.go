@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestResolveWorkerID(t *testing.T) {
+	tests := []struct {
+		name          string
+		hostname      string
+		err           error
+		wantGenerated bool
+	}{
+		{name: "hostname", hostname: "worker-1"},
+		{name: "empty hostname", hostname: "", wantGenerated: true},
+		{name: "hostname error", err: errors.New("no uts namespace"), wantGenerated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, generated := resolveWorkerID(func() (string, error) { return tt.hostname, tt.err })
+			if generated != tt.wantGenerated {
+				t.Fatalf("generated = %v, want %v", generated, tt.wantGenerated)
+			}
+			if !tt.wantGenerated && id != tt.hostname {
+				t.Errorf("id = %q, want hostname %q", id, tt.hostname)
+			}
+			if tt.wantGenerated && !uuidPattern.MatchString(id) {
+				t.Errorf("id = %q, want a version 4 UUID", id)
+			}
+		})
+	}
+}
+
+func TestWorkerIDDecorator(t *testing.T) {
+	tests := []struct {
+		name     string
+		workerID string
+		want     string
+	}{
+		{name: "empty worker id", workerID: "", want: "generated"},
+		{name: "worker id kept", workerID: "worker-1", want: "worker-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &model.TaskResult{WorkerId: tt.workerID}
+			workerIDDecorator("generated")(result)
+			if result.WorkerId != tt.want {
+				t.Errorf("WorkerId = %q, want %q", result.WorkerId, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkerMetadataDecoratorHostname(t *testing.T) {
+	tests := []struct {
+		name     string
+		workerID string
+	}{
+		{name: "hostname", workerID: "worker-1"},
+		{name: "generated id for empty hostname", workerID: newWorkerID()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &model.TaskResult{}
+			workerMetadataDecorator(tt.workerID)(result)
+			metadata, ok := result.OutputData[workerMetadataKey].(map[string]interface{})
+			if !ok {
+				t.Fatalf("output has no %s object: %v", workerMetadataKey, result.OutputData)
+			}
+			if metadata["hostname"] != tt.workerID {
+				t.Errorf("hostname = %v, want %q", metadata["hostname"], tt.workerID)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/conductor-sdk/conductor-go/sdk/client"
+	"github.com/conductor-sdk/conductor-go/sdk/model"
+)
+
+const (
+	// workflowCheckQueueSize bounds the checks awaiting their turn; further
+	// ones are dropped.
+	workflowCheckQueueSize = 100
+	// workflowCheckAttempts bounds the workflow state requests of each check,
+	// as Conductor decides the workflow asynchronously after a task update.
+	workflowCheckAttempts = 3
+	// workflowCheckDelay is the delay before each workflow state request.
+	workflowCheckDelay = time.Second
+)
+
+// newWorkflowCompletionCheck returns a completion handler that checks, after
+// each result of taskNames, whether the task's workflow reached a terminal
+// state and, if so, calls onDone with the task name and the workflow state.
+// List the tasks that end workflows: every other task only costs up to
+// workflowCheckAttempts needless requests. Checks happen on a background
+// goroutine, are dropped when too many are queued, and never delay or fail
+// the task.
+func newWorkflowCompletionCheck(workflowClient *client.WorkflowResourceApiService, taskNames map[string]bool, onDone func(taskName string, state model.WorkflowState)) func(string, *model.TaskResult) {
+	queue := make(chan webhookNotification, workflowCheckQueueSize)
+	go func() {
+		for n := range queue {
+			if state, ok := awaitWorkflowEnd(workflowClient, n.result.WorkflowInstanceId); ok {
+				onDone(n.taskName, state)
+			}
+		}
+	}()
+	return func(taskName string, result *model.TaskResult) {
+		if !taskNames[taskName] {
+			return
+		}
+		select {
+		case queue <- webhookNotification{taskName: taskName, result: result}:
+		default:
+			log.Printf("Workflow check: queue full, skipping workflow %s", result.WorkflowInstanceId)
+		}
+	}
+}
+
+// awaitWorkflowEnd returns the state of workflowID once it is terminal, false
+// if it is still running after workflowCheckAttempts checks or can't be read.
+func awaitWorkflowEnd(workflowClient *client.WorkflowResourceApiService, workflowID string) (model.WorkflowState, bool) {
+	for attempt := 1; attempt <= workflowCheckAttempts; attempt++ {
+		time.Sleep(workflowCheckDelay)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		state, _, err := workflowClient.GetWorkflowState(ctx, workflowID, false, false)
+		cancel()
+		if err != nil {
+			log.Printf("Workflow check: failed to get state of workflow %s: %v", workflowID, err)
+			return state, false
+		}
+		for _, s := range model.WorkflowTerminalStates {
+			if model.WorkflowStatus(state.Status) == s {
+				return state, true
+			}
+		}
+	}
+	return model.WorkflowState{}, false
+}
+
+// logWorkflowDone is the default workflow completion callback.
+func logWorkflowDone(taskName string, state model.WorkflowState) {
+	log.Printf("[request_id=%s] Workflow %s finished with status %s after %s", state.CorrelationId, state.WorkflowId, state.Status, taskName)
+}